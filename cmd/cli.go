@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"windows-resolution-manager/config"
@@ -15,6 +13,23 @@ import (
 func InitializeApp() {
 	// Define the --config-file flag
 	configFileFlag := flag.String("config-file", "./config.json", "Path to the configuration file")
+	// Define the --backend flag
+	backendFlag := flag.String("backend", "auto", "Display backend to use (auto, win32, xrandr, wlr-randr, mutter, kscreen, sysfs)")
+	// Define the --mutter-temporary flag
+	mutterTemporaryFlag := flag.Bool("mutter-temporary", false, "On GNOME, apply changes temporarily instead of persisting them to monitors.xml")
+
+	// Define the --confirm-timeout flag
+	confirmTimeoutFlag := flag.Int("confirm-timeout", -1, "Seconds to keep new settings before they are reverted, 0 keeps them right away (default: confirm_timeout from the config file, or 15)")
+
+	// Define the --auto-recover flag
+	autoRecoverFlag := flag.Bool("auto-recover", false, "Roll back a change that did not finish, e.g. after a crash, without asking")
+
+	// Define the --yes and --no-confirm flags
+	yesFlag := flag.Bool("yes", false, "Apply and keep changes without asking, for scripts and scheduled tasks")
+	noConfirmFlag := flag.Bool("no-confirm", false, "Same as --yes")
+
+	// Define the --dry-run flag
+	dryRunFlag := flag.Bool("dry-run", false, "Test and print the changes with the driver without applying them")
 
 	// Parse the flags
 	flag.Parse()
@@ -22,13 +37,39 @@ func InitializeApp() {
 	// Remaining arguments after flags
 	args := flag.Args()
 
+	// Select the display backend before anything talks to the displays
+	backend, err := display.NewBackend(*backendFlag)
+	if err != nil {
+		fmt.Println("Error selecting display backend:", err)
+		return
+	}
+	display.SetBackend(backend)
+	// On GNOME every change can be made temporary, set and configs can do so one at a time
+	if _, ok := backend.(*display.MutterBackend); ok && *mutterTemporaryFlag {
+		display.SetTemporary(true)
+	}
+
 	// Check if the config file exists; if not, create it with default configurations
-	err := config.EnsureConfigFile(*configFileFlag)
+	err = config.EnsureConfigFile(*configFileFlag)
 	if err != nil {
 		fmt.Println("Error ensuring configuration file:", err)
 		return
 	}
 
+	// Decide whether to ask before changes and how long to wait for them to be kept,
+	// a broken config file is reported by the config command
+	configs, err := config.LoadConfigurations(*configFileFlag)
+	if err != nil {
+		configs = nil
+	}
+	setupConfirmation(*yesFlag || *noConfirmFlag, *confirmTimeoutFlag, configs)
+	display.SetDryRun(*dryRunFlag)
+
+	// A change that never finished, e.g. because the machine crashed, can be rolled back
+	if !*dryRunFlag {
+		recoverUnfinishedChange(*autoRecoverFlag)
+	}
+
 	if len(args) == 0 {
 		// Start interactive mode
 		StartInteractiveMode(*configFileFlag)
@@ -41,23 +82,87 @@ func InitializeApp() {
 		PrintHelp()
 	case "list", "ls", "l":
 		HandleListCommand(args[1:])
+	case "current":
+		HandleCurrentCommand(args[1:])
 	case "set", "change", "ch", "c", "s":
 		HandleSetCommand(args[1:])
+	case "primary":
+		HandlePrimaryCommand(args[1:])
+	case "topology":
+		HandleTopologyCommand(args[1:])
+	case "enable":
+		HandleEnableCommand(args[1:], true)
+	case "disable":
+		HandleEnableCommand(args[1:], false)
 	case "config":
 		HandleConfigCommand(args[1:], *configFileFlag)
+	case "plan":
+		HandlePlanCommand(args[1:], *configFileFlag)
+	case "edid":
+		HandleEdidCommand(args[1:])
+	case "info":
+		HandleInfoCommand(args[1:])
+	case "snapshots":
+		HandleSnapshotsCommand()
+	case "restore":
+		HandleRestoreCommand(args[1:])
+	case "confirm":
+		HandleConfirmCommand()
+	case "reset":
+		HandleResetCommand()
 	default:
 		fmt.Println("Unknown command:", cmd)
 		PrintHelp()
 	}
 }
 
+// recoverUnfinishedChange offers to roll back a change left in the journal, or does it right away with autoRecover
+func recoverUnfinishedChange(autoRecover bool) {
+	entry, err := display.UnfinishedChange()
+	if err != nil {
+		fmt.Println("Error reading journal:", err)
+		return
+	}
+	if entry == nil {
+		return
+	}
+	fmt.Printf("A change started %s did not finish: %s. The settings before it were:\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Previous.Reason)
+	for _, m := range entry.Previous.Monitors {
+		fmt.Printf("  %s\n", m)
+	}
+	if !autoRecover {
+		// Without anybody to ask, only --auto-recover rolls back; the journal is
+		// kept so the next run that can ask offers it again
+		if !promptUser {
+			fmt.Println("Keeping the current settings, run wrm without --yes to decide or with --auto-recover to roll back.")
+			return
+		}
+		if !askYesNo("Roll back to these settings?") {
+			fmt.Println("Keeping the current settings.")
+			if err := display.EndChange(); err != nil {
+				fmt.Println("Error:", err)
+			}
+			return
+		}
+	}
+	err = display.RecoverChange(entry)
+	if err != nil {
+		fmt.Println("Error rolling back:", err)
+		return
+	}
+	fmt.Println("Rolled back to the previous settings.")
+}
+
 // StartInteractiveMode starts the interactive CLI session.
 func StartInteractiveMode(configFile string) {
 	fmt.Println("Entering interactive mode. Type 'help' for a list of commands.")
-	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Print("wrm> ")
-		input, _ := reader.ReadString('\n')
+		input, ok := stdin.ReadLine()
+		if !ok {
+			fmt.Println()
+			return
+		}
 		input = strings.TrimSpace(input)
 		args := strings.Fields(input)
 		if len(args) == 0 {
@@ -72,10 +177,34 @@ func StartInteractiveMode(configFile string) {
 			PrintHelp()
 		case "list", "ls", "l":
 			HandleListCommand(args[1:])
+		case "current":
+			HandleCurrentCommand(args[1:])
 		case "set", "change", "ch", "c", "s":
 			HandleSetCommand(args[1:])
+		case "primary":
+			HandlePrimaryCommand(args[1:])
+		case "topology":
+			HandleTopologyCommand(args[1:])
+		case "enable":
+			HandleEnableCommand(args[1:], true)
+		case "disable":
+			HandleEnableCommand(args[1:], false)
 		case "config":
 			HandleConfigCommand(args[1:], configFile)
+		case "plan":
+			HandlePlanCommand(args[1:], configFile)
+		case "edid":
+			HandleEdidCommand(args[1:])
+		case "info":
+			HandleInfoCommand(args[1:])
+		case "snapshots":
+			HandleSnapshotsCommand()
+		case "restore":
+			HandleRestoreCommand(args[1:])
+		case "confirm":
+			HandleConfirmCommand()
+		case "reset":
+			HandleResetCommand()
 		default:
 			fmt.Println("Unknown command:", cmd)
 			PrintHelp()
@@ -88,6 +217,20 @@ func HandleConfigCommand(args []string, configFile string) {
 	config.HandleConfigCommand(args, configFile)
 }
 
+// HandlePlanCommand processes the 'plan' command.
+func HandlePlanCommand(args []string, configFile string) {
+	if len(args) < 1 {
+		fmt.Println("Configuration is required for the plan command.")
+		fmt.Println("Usage: wrm plan <config_name/index>")
+		return
+	}
+	// Planning is applying the configuration as a dry run
+	dryRun := display.DryRun()
+	display.SetDryRun(true)
+	defer display.SetDryRun(dryRun)
+	config.HandleConfigCommand(args[:1], configFile)
+}
+
 // HandleListCommand processes the 'list' command.
 func HandleListCommand(args []string) {
 	if len(args) == 0 {
@@ -96,134 +239,287 @@ func HandleListCommand(args []string) {
 		if err != nil {
 			fmt.Println("Error listing monitors:", err)
 		}
-	} else if len(args) >= 1 {
-		// Determine if the first argument is a monitor index or a friendly name
-		monitorIdentifier := args[0]
-		var monitorIndex int
-		var err error
-
-		// Try to convert to integer
-		monitorIndex, err = strconv.Atoi(monitorIdentifier)
-		if err != nil {
-			// Not an integer, treat as friendly name
-			monitors, listErr := display.ListMonitors()
-			if listErr != nil {
-				fmt.Println("Error listing monitors:", listErr)
-				return
-			}
-			monitorIndex = -1 // Initialize with invalid index
-			for i, mi := range monitors {
-				if strings.EqualFold(mi.FriendlyName, monitorIdentifier) {
-					monitorIndex = i + 1 // Monitors are 1-indexed
-					break
-				}
-			}
-			if monitorIndex == -1 {
-				fmt.Printf("Monitor with friendly name '%s' not found.\n", monitorIdentifier)
-				return
-			}
-		}
-
-		// Adjust monitorIndex for 0-based indexing
-		zeroBasedIndex := monitorIndex - 1
+		return
+	}
 
-		// Validate monitorIndex
-		monitors, listErr := display.ListMonitors()
-		if listErr != nil {
-			fmt.Println("Error listing monitors:", listErr)
-			return
-		}
-		if zeroBasedIndex < 0 || zeroBasedIndex >= len(monitors) {
-			fmt.Println("Monitor index out of range.")
-			return
-		}
+	// Determine if the first argument is a monitor index, ID or friendly name
+	zeroBasedIndex, _, err := display.FindMonitor(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 
-		if len(args) == 1 {
-			// List resolutions for the monitor
-			display.ListResolutionsForMonitor(zeroBasedIndex)
-		} else if len(args) == 2 {
-			// List frequencies for the resolution on the monitor
-			resolution := args[1]
-			display.ListFrequenciesForResolution(zeroBasedIndex, resolution)
-		} else {
-			fmt.Println("Invalid list command.")
-			PrintHelp()
-		}
+	if len(args) == 1 {
+		// List resolutions for the monitor
+		display.ListResolutionsForMonitor(zeroBasedIndex)
+	} else if len(args) == 2 {
+		// List frequencies for the resolution on the monitor
+		resolution := args[1]
+		display.ListFrequenciesForResolution(zeroBasedIndex, resolution)
 	} else {
 		fmt.Println("Invalid list command.")
 		PrintHelp()
 	}
 }
 
+// HandleCurrentCommand processes the 'current' command.
+func HandleCurrentCommand(args []string) {
+	identifier := ""
+	if len(args) > 0 {
+		identifier = args[0]
+	}
+	err := display.PrintCurrentModes(identifier)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
 // HandleSetCommand processes the 'set' command.
 func HandleSetCommand(args []string) {
 	if len(args) < 1 {
 		fmt.Println("Monitor is required for the set command.")
-		fmt.Println("Usage: wrm set <monitor> [resolution] [frequency]")
+		fmt.Println("Usage: wrm set <monitor> [resolution] [frequency] [depth] [orientation] [--temporary]")
 		return
 	}
-	monitorInput := args[0]
-	var monitorIndex int
-	var err error
 
-	// Try to convert to integer
-	monitorIndex, err = strconv.Atoi(monitorInput)
-	if err != nil {
-		// Not an integer, treat as friendly name
-		monitors, listErr := display.ListMonitors()
-		if listErr != nil {
-			fmt.Println("Error listing monitors:", listErr)
-			return
+	// --temporary can go anywhere after the monitor
+	temporary := false
+	var rest []string
+	for _, arg := range args[1:] {
+		if arg == "--temporary" || arg == "-temporary" {
+			temporary = true
+			continue
 		}
-		monitorIndex = -1 // Initialize with invalid index
-		for i, mi := range monitors {
-			if strings.EqualFold(mi.FriendlyName, monitorInput) {
-				monitorIndex = i + 1 // Monitors are 1-indexed
-				break
+		rest = append(rest, arg)
+	}
+	args = append(args[:1], rest...)
+
+	zeroBasedIndex, mi, err := display.FindMonitor(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	deviceName := mi.DeviceName // Changed from FriendlyName to DeviceName
+
+	if len(args) == 1 {
+		// No resolution provided, list resolutions
+		display.ListResolutionsForMonitor(zeroBasedIndex)
+		fmt.Println("Usage: wrm set <monitor> <resolution> [frequency] [depth] [orientation] [--temporary]")
+		return
+	}
+
+	resolution := args[1]
+	var rate display.RefreshRate
+	rotationHint := ""
+	rest = args[2:]
+	if len(rest) > 0 {
+		// The frequency can be left out when the orientation is given by name or
+		// in degrees with "deg", a bare number such as 90 is always the frequency.
+		// It may be exact such as 59.94 or 60000/1001
+		if rateValue, err := display.ParseRefreshRate(rest[0]); err == nil {
+			if _, err := display.ParseOrientation(rest[0]); err == nil {
+				rotationHint = rest[0]
 			}
+			rate = rateValue
+			rest = rest[1:]
+		}
+	}
+	// The color depth ("16bit", "32bpp") and the orientation can come in any order
+	var bitsPerPixel uint32
+	var orientation *display.Orientation
+	for _, arg := range rest {
+		if depth, ok := parseBitDepth(arg); ok {
+			bitsPerPixel = depth
+			continue
 		}
-		if monitorIndex == -1 {
-			fmt.Printf("Monitor with friendly name '%s' not found.\n", monitorInput)
+		o, err := display.ParseOrientation(arg)
+		if err != nil {
+			fmt.Println("Invalid frequency, color depth or orientation:", arg)
 			return
 		}
+		orientation = &o
 	}
 
-	// Adjust monitorIndex for 0-based indexing
-	zeroBasedIndex := monitorIndex - 1
+	// A temporary change leaves the stored mode alone, 'wrm reset' or the end of the session brings it back
+	if temporary {
+		previous := display.Temporary()
+		if err := display.SetTemporary(true); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer display.SetTemporary(previous)
+	}
 
-	// Retrieve the list of monitors
-	monitors, err := display.ListMonitors()
+	err = display.SetResolution(deviceName, resolution, rate, bitsPerPixel, orientation)
+	if err != nil && err != display.ErrCancelled && err != display.ErrDryRun {
+		fmt.Println("Error setting resolution:", err)
+		if rotationHint != "" {
+			fmt.Printf("%s was taken as the frequency, write %sdeg or the orientation name to rotate the monitor.\n", rotationHint, rotationHint)
+		}
+	} else if err == nil && temporary {
+		if display.CanReset() {
+			fmt.Println("The change is temporary, run 'wrm reset' to return to the stored mode.")
+		} else {
+			fmt.Println("The change is temporary, it lasts until the session ends.")
+		}
+	}
+}
+
+// parseBitDepth parses a color depth written as "16bit" or "16bpp"
+func parseBitDepth(arg string) (uint32, bool) {
+	arg = strings.ToLower(arg)
+	for _, suffix := range []string{"bit", "bpp"} {
+		if strings.HasSuffix(arg, suffix) {
+			depth, err := strconv.Atoi(strings.TrimSuffix(arg, suffix))
+			if err == nil && depth > 0 {
+				return uint32(depth), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// HandlePrimaryCommand processes the 'primary' command.
+func HandlePrimaryCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Monitor is required for the primary command.")
+		fmt.Println("Usage: wrm primary <monitor>")
+		return
+	}
+
+	_, mi, err := display.FindMonitor(args[0])
 	if err != nil {
-		fmt.Println("Error listing monitors:", err)
+		fmt.Println("Error:", err)
 		return
 	}
-	if zeroBasedIndex < 0 || zeroBasedIndex >= len(monitors) {
-		fmt.Println("Monitor index out of range.")
+
+	err = display.SetPrimary(mi.DeviceName)
+	if err == display.ErrCancelled || err == display.ErrDryRun {
 		return
+	} else if err != nil {
+		fmt.Println("Error setting primary monitor:", err)
+	} else {
+		fmt.Printf("%s is now the primary monitor.\n", mi.FriendlyName)
 	}
-	mi := monitors[zeroBasedIndex]
-	deviceName := mi.DeviceName // Changed from FriendlyName to DeviceName
+}
 
-	if len(args) == 1 {
-		// No resolution provided, list resolutions
-		display.ListResolutionsForMonitor(zeroBasedIndex)
-		fmt.Println("Usage: wrm set <monitor> <resolution> [frequency]")
+// HandleEnableCommand processes the 'enable' and 'disable' commands.
+func HandleEnableCommand(args []string, enable bool) {
+	command := "disable"
+	if enable {
+		command = "enable"
+	}
+	if len(args) < 1 {
+		fmt.Printf("Monitor is required for the %s command.\n", command)
+		fmt.Printf("Usage: wrm %s <monitor>\n", command)
 		return
 	}
 
-	resolution := args[1]
-	frequency := uint32(0)
-	if len(args) >= 3 {
-		freqValue, err := strconv.Atoi(args[2])
-		if err != nil {
-			fmt.Println("Invalid frequency:", args[2])
+	// Monitors that are switched off can only be found among all monitors
+	_, mi, err := display.FindAnyMonitor(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if (mi.Status != display.MONITOR_STATUS_DISABLED) == enable {
+		fmt.Printf("%s is already %sd.\n", mi.FriendlyName, command)
+		return
+	}
+
+	err = display.SetMonitorsEnabled(map[string]bool{mi.MonitorID: enable})
+	if err == display.ErrCancelled || err == display.ErrDryRun {
+		return
+	} else if err != nil {
+		fmt.Printf("Error trying to %s monitor: %v\n", command, err)
+	} else {
+		fmt.Printf("%s %sd.\n", mi.FriendlyName, command)
+	}
+}
+
+// HandleTopologyCommand processes the 'topology' command.
+func HandleTopologyCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Topology is required for the topology command.")
+		fmt.Println("Usage: wrm topology <extend|clone|internal|external>")
+		return
+	}
+
+	topology, err := display.ParseTopology(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	err = display.SetTopology(topology)
+	if err == display.ErrCancelled || err == display.ErrDryRun {
+		return
+	} else if err != nil {
+		fmt.Println("Error changing topology:", err)
+		return
+	}
+	fmt.Printf("Switched to the %s topology:\n", topology)
+	err = display.PrintMonitors()
+	if err != nil {
+		fmt.Println("Error listing monitors:", err)
+	}
+}
+
+// HandleSnapshotsCommand processes the 'snapshots' command.
+func HandleSnapshotsCommand() {
+	err := display.PrintSnapshots()
+	if err != nil {
+		fmt.Println("Error listing snapshots:", err)
+	}
+}
+
+// HandleRestoreCommand processes the 'restore' command.
+func HandleRestoreCommand(args []string) {
+	snapshots, err := display.LoadSnapshots()
+	if err != nil {
+		fmt.Println("Error loading snapshots:", err)
+		return
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots stored.")
+		return
+	}
+
+	// Without an argument the newest snapshot is restored
+	index := 1
+	if len(args) > 0 {
+		index, err = strconv.Atoi(args[0])
+		if err != nil || index < 1 || index > len(snapshots) {
+			fmt.Printf("Invalid snapshot '%s', 'wrm snapshots' lists the stored ones.\n", args[0])
 			return
 		}
-		frequency = uint32(freqValue)
 	}
+	snapshot := snapshots[index-1]
+	fmt.Printf("Restoring the snapshot of %s, taken before %s.\n", snapshot.Time.Format("2006-01-02 15:04:05"), snapshot.Reason)
 
-	err = display.SetResolution(deviceName, resolution, frequency)
+	err = display.RestoreSnapshot(snapshot)
+	if err == display.ErrCancelled || err == display.ErrDryRun {
+		return
+	} else if err != nil {
+		fmt.Println("Error restoring snapshot:", err)
+	} else {
+		fmt.Println("Snapshot restored.")
+	}
+}
+
+// HandleResetCommand processes the 'reset' command, which undoes temporary changes
+func HandleResetCommand() {
+	err := display.ResetModes()
+	if err != nil && err != display.ErrCancelled && err != display.ErrDryRun {
+		fmt.Println("Error resetting display settings:", err)
+	}
+}
+
+// HandleConfirmCommand processes the 'confirm' command.
+func HandleConfirmCommand() {
+	err := display.ConfirmPending()
 	if err != nil {
-		fmt.Println("Error setting resolution:", err)
+		fmt.Println("Error:", err)
+		return
 	}
+	fmt.Println("Changes confirmed.")
 }
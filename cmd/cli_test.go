@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"windows-resolution-manager/display"
+)
+
+// newFakeDisplays installs a fake backend with a 1080p monitor and a second
+// one, and keeps the state files of the test in a temporary directory
+func newFakeDisplays(t *testing.T) *display.FakeBackend {
+	t.Helper()
+	f := display.NewFakeBackend()
+	f.AddMonitor("27G2G5", "DISPLAY1",
+		display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+		display.Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+		display.Mode{Width: 1920, Height: 1080, Frequency: 59, BitsPerPixel: 32},
+		display.Mode{Width: 1920, Height: 1080, Frequency: 60, Interlaced: true, BitsPerPixel: 32},
+		display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32},
+		display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 16},
+	)
+	f.AddMonitor("VG248", "DISPLAY2",
+		display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+		display.Mode{Width: 1280, Height: 1024, Frequency: 75, BitsPerPixel: 32},
+	)
+	previous := display.CurrentBackend()
+	display.SetBackend(f)
+	display.SetStateDir(t.TempDir())
+	t.Cleanup(func() { display.SetBackend(previous) })
+	return f
+}
+
+func TestHandleSetCommand(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		device          string
+		wantMode        display.Mode
+		wantOrientation display.Orientation
+		wantApplied     bool
+		wantStored      bool // The mode is also the one returned to by reset
+	}{
+		{
+			name:        "resolution and frequency",
+			args:        []string{"1", "1280x720", "60"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "highest frequency when none is given",
+			args:        []string{"1", "1920x1080"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "frequency in the resolution",
+			args:        []string{"1", "1920x1080@144"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "exact rate on a whole Hz mode",
+			args:        []string{"1", "1920x1080", "59.94"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1920, Height: 1080, Frequency: 59, Rate: display.RefreshRate{Numerator: 5994, Denominator: 100}, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "interlaced only when asked for",
+			args:        []string{"1", "1920x1080i", "60"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1920, Height: 1080, Frequency: 60, Interlaced: true, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "color depth",
+			args:        []string{"1", "1280x720", "60", "16bit"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 16},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:            "portrait resolution",
+			args:            []string{"2", "1080x1920", "60", "portrait"},
+			device:          "DISPLAY2",
+			wantMode:        display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+			wantOrientation: display.OrientationPortrait,
+			wantApplied:     true,
+			wantStored:      true,
+		},
+		{
+			name:            "rotation in degrees without a frequency",
+			args:            []string{"1", "1920x1080", "90deg"},
+			device:          "DISPLAY1",
+			wantMode:        display.Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+			wantOrientation: display.OrientationPortrait,
+			wantApplied:     true,
+			wantStored:      true,
+		},
+		{
+			name:            "rotation in degrees after the frequency",
+			args:            []string{"2", "1920x1080", "60", "270"},
+			device:          "DISPLAY2",
+			wantMode:        display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+			wantOrientation: display.OrientationPortraitFlipped,
+			wantApplied:     true,
+			wantStored:      true,
+		},
+		{
+			name:   "bare number is the frequency",
+			args:   []string{"1", "1920x1080", "90"},
+			device: "DISPLAY1",
+		},
+		{
+			name:        "monitor by friendly name",
+			args:        []string{"VG248", "1280x1024", "75"},
+			device:      "DISPLAY2",
+			wantMode:    display.Mode{Width: 1280, Height: 1024, Frequency: 75, BitsPerPixel: 32},
+			wantApplied: true,
+			wantStored:  true,
+		},
+		{
+			name:        "temporary",
+			args:        []string{"1", "1280x720", "60", "--temporary"},
+			device:      "DISPLAY1",
+			wantMode:    display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32},
+			wantApplied: true,
+		},
+		{
+			name:   "unsupported resolution",
+			args:   []string{"1", "800x600"},
+			device: "DISPLAY1",
+		},
+		{
+			name:   "unsupported frequency",
+			args:   []string{"2", "1280x1024", "60"},
+			device: "DISPLAY2",
+		},
+		{
+			name:   "unknown monitor",
+			args:   []string{"3", "1920x1080"},
+			device: "DISPLAY1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			initial := f.Current[tt.device]
+			HandleSetCommand(tt.args)
+
+			if !tt.wantApplied {
+				if len(f.Applied) != 0 || f.Current[tt.device] != initial {
+					t.Fatalf("nothing should change, got %v on %s", f.Current[tt.device], tt.device)
+				}
+				return
+			}
+			if got := f.Current[tt.device]; got != tt.wantMode {
+				t.Errorf("mode = %v, want %v", got, tt.wantMode)
+			}
+			if got := f.Orientations[tt.device]; got != tt.wantOrientation {
+				t.Errorf("orientation = %v, want %v", got, tt.wantOrientation)
+			}
+			if stored := f.Stored[tt.device] == tt.wantMode; stored != tt.wantStored {
+				t.Errorf("stored mode = %v, want it stored: %v", f.Stored[tt.device], tt.wantStored)
+			}
+			if display.Temporary() {
+				t.Errorf("changes are still temporary after the command")
+			}
+		})
+	}
+}
+
+func TestHandleEnableCommand(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		enable        bool
+		startDisabled bool
+		wantDisabled  bool
+		wantSnapshots int
+	}{
+		{name: "disable", args: []string{"2"}, wantDisabled: true, wantSnapshots: 1},
+		{name: "enable", args: []string{"VG248"}, enable: true, startDisabled: true, wantSnapshots: 1},
+		{name: "already enabled", args: []string{"2"}, enable: true},
+		{name: "unknown monitor", args: []string{"5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			f.Disabled["DISPLAY2"] = tt.startDisabled
+			HandleEnableCommand(tt.args, tt.enable)
+
+			if f.Disabled["DISPLAY2"] != tt.wantDisabled {
+				t.Errorf("disabled = %v, want %v", f.Disabled["DISPLAY2"], tt.wantDisabled)
+			}
+			// Switching monitors is a change like any other, with its snapshot and journal
+			snapshots, err := display.LoadSnapshots()
+			if err != nil || len(snapshots) != tt.wantSnapshots {
+				t.Errorf("%d snapshots stored (%v), want %d", len(snapshots), err, tt.wantSnapshots)
+			}
+			if entry, err := display.UnfinishedChange(); err != nil || entry != nil {
+				t.Errorf("journal left behind: %v, %v", entry, err)
+			}
+		})
+	}
+}
+
+func TestHandleRestoreCommand(t *testing.T) {
+	f := newFakeDisplays(t)
+	prompts := 0
+	display.SetConfirmHook(func(prompt display.Prompt) bool {
+		prompts++
+		return true
+	})
+	t.Cleanup(func() { display.SetConfirmHook(nil) })
+
+	HandleSetCommand([]string{"2", "1280x1024", "75"})
+	HandleEnableCommand([]string{"2"}, false)
+	// The second newest snapshot is the one from before the mode change, with the monitor on
+	HandleRestoreCommand([]string{"2"})
+
+	if f.Disabled["DISPLAY2"] {
+		t.Errorf("DISPLAY2 is still switched off")
+	}
+	if want := (display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}); f.Current["DISPLAY2"] != want {
+		t.Errorf("DISPLAY2: mode = %v, want %v", f.Current["DISPLAY2"], want)
+	}
+	if prompts != 3 {
+		t.Errorf("asked %d times, want once per command", prompts)
+	}
+}
+
+func TestHandleTopologyCommand(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantDisabled  bool
+		wantSnapshots int
+	}{
+		{name: "extend switches every monitor on", args: []string{"extend"}, wantSnapshots: 1},
+		// Neither fake monitor is a built-in panel
+		{name: "internal without a built-in panel", args: []string{"internal"}, wantDisabled: true},
+		{name: "clone needs native support", args: []string{"clone"}, wantDisabled: true},
+		{name: "unknown topology", args: []string{"mirror"}, wantDisabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			f.Disabled["DISPLAY2"] = true
+			HandleTopologyCommand(tt.args)
+
+			if f.Disabled["DISPLAY2"] != tt.wantDisabled {
+				t.Errorf("disabled = %v, want %v", f.Disabled["DISPLAY2"], tt.wantDisabled)
+			}
+			snapshots, err := display.LoadSnapshots()
+			if err != nil || len(snapshots) != tt.wantSnapshots {
+				t.Errorf("%d snapshots stored (%v), want %d", len(snapshots), err, tt.wantSnapshots)
+			}
+			if entry, err := display.UnfinishedChange(); err != nil || entry != nil {
+				t.Errorf("journal left behind: %v, %v", entry, err)
+			}
+		})
+	}
+}
+
+// captureOutput returns what fn prints to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// sessionBackend applies temporary changes but has no stored modes to reset
+// to, like xrandr and wlr-randr
+type sessionBackend struct {
+	display.Backend
+	display.TemporaryApplier
+}
+
+func TestTemporaryHint(t *testing.T) {
+	tests := []struct {
+		name    string
+		session bool
+		want    string
+	}{
+		{name: "backend with reset", want: "run 'wrm reset' to return to the stored mode"},
+		{name: "backend without reset", session: true, want: "it lasts until the session ends"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			if tt.session {
+				display.SetBackend(sessionBackend{Backend: f, TemporaryApplier: f})
+			}
+			out := captureOutput(t, func() { HandleSetCommand([]string{"1", "1280x720", "60", "--temporary"}) })
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q does not contain %q", out, tt.want)
+			}
+			if tt.session && strings.Contains(out, "wrm reset") {
+				t.Errorf("output %q offers a reset the backend cannot do", out)
+			}
+			if want := (display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32}); f.Current["DISPLAY1"] != want {
+				t.Errorf("mode = %v, want %v", f.Current["DISPLAY1"], want)
+			}
+		})
+	}
+}
+
+func TestHandlePrimaryCommand(t *testing.T) {
+	f := newFakeDisplays(t)
+	prompts := 0
+	display.SetConfirmHook(func(prompt display.Prompt) bool {
+		prompts++
+		return true
+	})
+	t.Cleanup(func() { display.SetConfirmHook(nil) })
+
+	HandlePrimaryCommand([]string{"2"})
+
+	if f.Primary != "DISPLAY2" {
+		t.Errorf("primary = %q, want DISPLAY2", f.Primary)
+	}
+	// The fake backend has no primary at the origin, so the desktop is shifted back to start at 0,0
+	if f.Positions["DISPLAY1"] != (display.Position{}) || f.Positions["DISPLAY2"] != (display.Position{X: 1920}) {
+		t.Errorf("positions = %v, want DISPLAY1 at 0,0 and DISPLAY2 at 1920,0", f.Positions)
+	}
+	if prompts != 1 {
+		t.Errorf("asked %d times, want once", prompts)
+	}
+	// Moving every monitor is a change like any other, with its snapshot and journal
+	snapshots, err := display.LoadSnapshots()
+	if err != nil || len(snapshots) != 1 {
+		t.Errorf("%d snapshots stored (%v), want 1", len(snapshots), err)
+	}
+	if entry, err := display.UnfinishedChange(); err != nil || entry != nil {
+		t.Errorf("journal left behind: %v, %v", entry, err)
+	}
+}
+
+func TestHandleSetCommandRollsBack(t *testing.T) {
+	f := newFakeDisplays(t)
+	// The mode passes the test but the switch fails halfway
+	f.Broken["DISPLAY1"] = display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32}
+
+	out := captureOutput(t, func() { HandleSetCommand([]string{"1", "1280x720", "60"}) })
+
+	if !strings.Contains(out, "the previous settings were restored") {
+		t.Errorf("output %q does not report the rollback", out)
+	}
+	if want := (display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}); f.Current["DISPLAY1"] != want {
+		t.Errorf("mode = %v, want %v", f.Current["DISPLAY1"], want)
+	}
+	if entry, err := display.UnfinishedChange(); err != nil || entry != nil {
+		t.Errorf("journal left behind: %v, %v", entry, err)
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"windows-resolution-manager/config"
+	"windows-resolution-manager/display"
+)
+
+// Confirmation policies, set with "confirm" in the config file
+const (
+	CONFIRM_ALWAYS   = "always"   // Ask before every change and wait for it to be kept
+	CONFIRM_NEVER    = "never"    // Apply and keep changes without asking, like --yes
+	CONFIRM_TTY_ONLY = "tty-only" // Ask only when stdin is a terminal, e.g. not in scheduled tasks or pipes
+)
+
+// promptUser tells whether the user is asked at all, it follows the confirmation policy
+var promptUser = true
+
+// setupConfirmation applies the confirmation policy and hands the prompts to the display package.
+// A negative timeout takes confirm_timeout from the configurations, or the default.
+func setupConfirmation(noConfirm bool, timeout int, configs *config.Configurations) {
+	policy := CONFIRM_ALWAYS
+	if configs != nil && configs.Confirm != "" {
+		policy = strings.ToLower(configs.Confirm)
+	}
+	if noConfirm {
+		policy = CONFIRM_NEVER
+	}
+	switch policy {
+	case CONFIRM_ALWAYS:
+		promptUser = true
+	case CONFIRM_NEVER:
+		promptUser = false
+	case CONFIRM_TTY_ONLY:
+		promptUser = stdinIsTerminal()
+	default:
+		fmt.Printf("Invalid confirm policy '%s' in the config file, use always, never or tty-only.\n", configs.Confirm)
+		promptUser = true
+	}
+
+	display.SetConfirmHook(confirmChange)
+	if promptUser {
+		display.SetKeepHook(waitForEnter)
+	} else {
+		display.SetKeepHook(waitForConfirmCommand)
+	}
+
+	// Without anybody to ask, changes are kept right away unless a timeout is given explicitly
+	if timeout < 0 {
+		timeout = 0
+		if promptUser {
+			timeout = int(display.DEFAULT_CONFIRM_TIMEOUT / time.Second)
+			if configs != nil && configs.ConfirmTimeout != nil {
+				timeout = *configs.ConfirmTimeout
+			}
+		}
+	}
+	display.SetConfirmTimeout(time.Duration(timeout) * time.Second)
+}
+
+// stdinIsTerminal reports whether stdin is a console rather than a pipe or a file
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// askYesNo asks the question on the terminal, the answer is yes when the policy says not to ask
+func askYesNo(question string) bool {
+	if !promptUser {
+		return true
+	}
+	fmt.Printf("%s (y/n): ", question)
+	response, _ := stdin.ReadLine()
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+// confirmChange is the confirmation hook of the display package, it shows what is about to change before asking
+func confirmChange(prompt display.Prompt) bool {
+	printPrompt(prompt)
+	if askYesNo(prompt.Question) {
+		return true
+	}
+	fmt.Println("Operation cancelled.")
+	return false
+}
+
+// printPrompt lists the topology, monitors and modes a change is about to switch,
+// unless the question already names its only change
+func printPrompt(prompt display.Prompt) {
+	if prompt.Topology == "" && len(prompt.Toggles) == 0 &&
+		(len(prompt.Changes) == 0 || (len(prompt.Changes) == 1 && strings.Contains(prompt.Question, prompt.Changes[0].Describe()))) {
+		return
+	}
+	fmt.Println("The following changes will be applied:")
+	if prompt.Topology != "" {
+		fmt.Printf("  %s topology\n", prompt.Topology)
+	}
+	ids := make([]string, 0, len(prompt.Toggles))
+	for id := range prompt.Toggles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if prompt.Toggles[id] {
+			fmt.Printf("  %s: on\n", id)
+		} else {
+			fmt.Printf("  %s: off\n", id)
+		}
+	}
+	for _, c := range prompt.Changes {
+		fmt.Printf("  %s: %s\n", c.DeviceName, c.Describe())
+	}
+}
+
+// waitForEnter is the keep hook of the display package. It reports true when a
+// line is entered and false when stdin is closed or the wait is over, in which
+// case the next line is left to the prompt after it.
+func waitForEnter(timeout time.Duration, done <-chan struct{}) bool {
+	fmt.Printf("Keep these changes? Press Enter or run 'wrm confirm' within %d seconds, otherwise they are reverted.\n", int(timeout.Seconds()))
+	select {
+	case _, ok := <-stdin.Lines():
+		return ok
+	case <-done:
+		return false
+	}
+}
+
+// waitForConfirmCommand is the keep hook when nobody is asked, only 'wrm confirm' from another shell keeps the changes
+func waitForConfirmCommand(timeout time.Duration, done <-chan struct{}) bool {
+	fmt.Printf("Keep these changes? Run 'wrm confirm' within %d seconds, otherwise they are reverted.\n", int(timeout.Seconds()))
+	<-done
+	return false
+}
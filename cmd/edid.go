@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"windows-resolution-manager/display"
+	"windows-resolution-manager/edid"
+)
+
+// HandleEdidCommand processes the 'edid' command.
+func HandleEdidCommand(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Println("Usage: wrm edid <monitor> [raw]")
+		return
+	}
+
+	_, mi, err := display.FindMonitor(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	data, err := display.ReadEDID(mi.DeviceName)
+	if err != nil {
+		fmt.Println("Error reading EDID:", err)
+		return
+	}
+
+	if len(args) == 2 {
+		if !strings.EqualFold(args[1], "raw") {
+			fmt.Println("Usage: wrm edid <monitor> [raw]")
+			return
+		}
+		fmt.Print(edid.HexDump(data))
+		return
+	}
+
+	decoded, err := edid.Parse(data)
+	if err != nil {
+		fmt.Println("Error decoding EDID:", err)
+		fmt.Print(edid.HexDump(data))
+		return
+	}
+	fmt.Printf("EDID of %s (%s):\n", mi.FriendlyName, mi.DeviceName)
+	fmt.Print(decoded)
+}
+
+// HandleInfoCommand processes the 'info' command.
+func HandleInfoCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: wrm info <monitor>")
+		return
+	}
+
+	_, mi, err := display.FindMonitor(args[0])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	data, err := display.ReadEDID(mi.DeviceName)
+	if err != nil {
+		fmt.Println("Error reading EDID:", err)
+		return
+	}
+	decoded, err := edid.Parse(data)
+	if err != nil {
+		fmt.Println("Error decoding EDID:", err)
+		return
+	}
+	fmt.Printf("Capabilities of %s (%s):\n", mi.FriendlyName, mi.DeviceName)
+	fmt.Print(decoded.Capabilities())
+}
@@ -5,16 +5,39 @@ import "fmt"
 // PrintHelp displays the combined help message
 func PrintHelp() {
 	helpMessage := `
-Usage: wrm [--config-file <path>] <command> [arguments]
+Usage: wrm [--config-file <path>] [--backend <name>] <command> [arguments]
 
 Commands:
   help                                Show this help message
   list                                List all monitors
   list <monitor>                      List resolutions for the specified monitor
   list <monitor> <resolution>         List frequencies for the specified resolution on the monitor
+  current [monitor]                   Show the mode, rotation and position every monitor is running
   set <monitor> <resolution> [freq]    Set the resolution and frequency for the specified monitor
+  set <monitor> <resolution> [freq] [depth] [orientation]
+                                      Also pick the color depth (e.g. 16bit) or rotate the monitor: 0deg, 90deg,
+                                      180deg, 270deg, landscape, portrait, flipped or portrait-flipped. A bare
+                                      number right after the resolution is always the frequency
+  set <monitor> <resolution> ... --temporary
+                                      Apply the mode without storing it as the default, it is gone after a reboot
+  reset                               Return every monitor to its stored mode, undoing temporary changes (Windows)
+  primary <monitor>                   Make the monitor the primary one, moving it to 0,0
+  topology <extend|clone|internal|external>
+                                      Switch between the Win+P presets, e.g. projector only with 'external'
+  enable <monitor>                    Switch a monitor on
+  disable <monitor>                   Switch a monitor off
   config                              List pre-configured settings
   config <config_name/index>          Apply a saved configuration by name or index
+  plan <config_name/index>            Test a configuration with the driver and show what it would change, without applying it
+  edid <monitor> [raw]                Show the decoded EDID of the monitor, or its raw hex with 'raw'
+  info <monitor>                      Show HDR support, HDMI link rates and advertised video formats
+  snapshots                           List the display states saved before every change, newest first
+  restore [snapshot]                  Go back to a saved display state, the newest one by default
+  confirm                             Keep the changes another wrm is waiting on, e.g. when its window is not visible
+
+<monitor> can be the index or the id shown by 'wrm list', or the monitor friendly name.
+<resolution> ending in 'i' (1920x1080i) selects an interlaced mode, these are never picked otherwise.
+[freq] can be exact, e.g. 59.94 or 60000/1001, to tell 59.94 Hz and 60 Hz modes apart.
 
 Aliases:
   list -> ls, l
@@ -22,17 +45,44 @@ Aliases:
 
 Flags:
   --config-file <path>                Specify a custom configuration file path (default: ./config.json)
+  --backend <name>                    Display backend: auto, win32, xrandr, wlr-randr, mutter, kscreen, sysfs (default: auto)
+  --mutter-temporary                  On GNOME, apply changes temporarily instead of persisting them
+  --confirm-timeout <seconds>         Time to keep new settings before they are reverted, 0 keeps them right away (default: 15)
+  --auto-recover                      Roll back a change that did not finish (e.g. after a crash) without asking
+  --yes, --no-confirm                 Apply and keep changes without asking, for scripts and scheduled tasks
+  --dry-run                           Test set, config and the other changes with the driver and print them, nothing is applied
 
 Examples:
   wrm list
   wrm ls 1
   wrm ls 27G2G5
   wrm l 2 1920x1080
+  wrm current
+  wrm current 2
   wrm set 1 1280x720 60
   wrm set 27G2G5 1280x720 60
+  wrm set AOC-2702-DP-1 1280x720 60
+  wrm set 2 2560x1440 144 portrait
+  wrm set 1 1280x720 60 16bit
+  wrm set 1 1920x1080i@60
+  wrm set 1 1920x1080 59.94
+  wrm set 1 1280x720 60 --temporary
+  wrm reset
+  wrm primary 2
+  wrm disable 3
+  wrm topology external
   wrm config
   wrm config "Gaming Setup"
   wrm config 2
+  wrm plan "Dual Setup"
+  wrm --dry-run set 1 1920x1080 60
+  wrm --yes config "Gaming Setup"
+  wrm edid 1
+  wrm edid 27G2G5 raw
+  wrm info 1
+  wrm snapshots
+  wrm restore
+  wrm restore 3
 `
 	fmt.Println(helpMessage)
 }
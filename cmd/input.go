@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// lineReader hands out the lines of its source to whoever asks for the next
+// one. A single goroutine reads the source, so a wait for Enter that gives up
+// leaves the next line to the prompt after it instead of swallowing it.
+type lineReader struct {
+	source io.Reader
+	once   sync.Once
+	lines  chan string
+}
+
+// stdin is the reader every prompt of the cli goes through
+var stdin = newLineReader(os.Stdin)
+
+// newLineReader returns a reader of the lines of source, nothing is read until a line is asked for
+func newLineReader(source io.Reader) *lineReader {
+	return &lineReader{source: source, lines: make(chan string)}
+}
+
+// Lines returns the channel the lines arrive on, it is closed at the end of the source
+func (r *lineReader) Lines() <-chan string {
+	r.once.Do(func() {
+		go func() {
+			scanner := bufio.NewScanner(r.source)
+			for scanner.Scan() {
+				r.lines <- strings.TrimRight(scanner.Text(), "\r")
+			}
+			close(r.lines)
+		}()
+	})
+	return r.lines
+}
+
+// ReadLine waits for the next line, it reports false at the end of the source
+func (r *lineReader) ReadLine() (string, bool) {
+	line, ok := <-r.Lines()
+	return line, ok
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// pipeStdin replaces the stdin of the cli with a pipe the test writes to
+func pipeStdin(t *testing.T) *io.PipeWriter {
+	t.Helper()
+	r, w := io.Pipe()
+	previous := stdin
+	stdin = newLineReader(r)
+	t.Cleanup(func() {
+		w.Close()
+		stdin = previous
+	})
+	return w
+}
+
+func TestWaitForEnterLeavesNextLineAfterTimeout(t *testing.T) {
+	w := pipeStdin(t)
+
+	// The wait is over before anything is typed, like after a timeout or 'wrm confirm'
+	done := make(chan struct{})
+	result := make(chan bool)
+	go func() { result <- waitForEnter(time.Second, done) }()
+	close(done)
+	select {
+	case kept := <-result:
+		if kept {
+			t.Fatal("waitForEnter reported a keypress without input")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitForEnter did not return once the wait was over")
+	}
+
+	// The next line goes to the prompt after the wait
+	go io.WriteString(w, "list\n")
+	if line, ok := stdin.ReadLine(); !ok || line != "list" {
+		t.Errorf("ReadLine() = %q, %v, want \"list\"", line, ok)
+	}
+}
+
+func TestWaitForEnter(t *testing.T) {
+	w := pipeStdin(t)
+	go io.WriteString(w, "\n")
+	if !waitForEnter(time.Second, make(chan struct{})) {
+		t.Error("waitForEnter did not report Enter")
+	}
+	w.Close()
+	if waitForEnter(time.Second, make(chan struct{})) {
+		t.Error("waitForEnter reported a keypress on a closed stdin")
+	}
+}
+
+func TestAskYesNo(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		prompt bool
+		want   bool
+	}{
+		{"yes", "y\n", true, true},
+		{"upper case yes", "Y\r\n", true, true},
+		{"no", "n\n", true, false},
+		{"empty answer", "\n", true, false},
+		{"not prompting", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := pipeStdin(t)
+			previous := promptUser
+			promptUser = tt.prompt
+			defer func() { promptUser = previous }()
+			go io.WriteString(w, tt.input)
+			if got := askYesNo("Apply?"); got != tt.want {
+				t.Errorf("askYesNo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
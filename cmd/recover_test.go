@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+	"windows-resolution-manager/display"
+)
+
+func TestRecoverUnfinishedChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		prompt       bool
+		answer       string
+		autoRecover  bool
+		wantRollback bool
+		wantJournal  bool // The change is still offered on the next start
+	}{
+		{name: "asked, yes", prompt: true, answer: "y\n", wantRollback: true},
+		{name: "asked, no", prompt: true, answer: "n\n"},
+		{name: "not asked", wantJournal: true},
+		{name: "not asked with auto-recover", autoRecover: true, wantRollback: true},
+		{name: "auto-recover", prompt: true, autoRecover: true, wantRollback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			previous, err := display.CaptureSnapshot("set 1280x720 @ 60 Hz on DISPLAY1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The change was applied, then WRM died before it was kept
+			if err := display.BeginChange(previous); err != nil {
+				t.Fatal(err)
+			}
+			changed := display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32}
+			f.Current["DISPLAY1"] = changed
+
+			w := pipeStdin(t)
+			go io.WriteString(w, tt.answer)
+			saved := promptUser
+			promptUser = tt.prompt
+			defer func() { promptUser = saved }()
+			recoverUnfinishedChange(tt.autoRecover)
+
+			want := changed
+			if tt.wantRollback {
+				want = previous.Monitors[0].Mode
+			}
+			if got := f.Current["DISPLAY1"]; got != want {
+				t.Errorf("mode = %v, want %v", got, want)
+			}
+			entry, err := display.UnfinishedChange()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (entry != nil) != tt.wantJournal {
+				t.Errorf("journal left = %v, want %v", entry != nil, tt.wantJournal)
+			}
+		})
+	}
+}
@@ -10,18 +10,174 @@ import (
 	"windows-resolution-manager/display"
 )
 
-// Config represents a display configuration
-type Config struct {
-	Name        string `json:"name"`
-	Monitor     int    `json:"monitor"`      // Optional if MonitorName is used
-	MonitorName string `json:"monitor_name"` // Optional if Monitor is used
+// MonitorConfig represents the settings of a single monitor
+type MonitorConfig struct {
+	Monitor     int    `json:"monitor"`              // Optional if MonitorName or MonitorID is used
+	MonitorName string `json:"monitor_name"`         // Optional if Monitor or MonitorID is used
+	MonitorID   string `json:"monitor_id,omitempty"` // Stable ID printed by 'wrm list', preferred over the others
 	Resolution  string `json:"resolution"`
 	Frequency   uint32 `json:"frequency"`
+	RefreshRate string `json:"refresh_rate,omitempty"` // Exact rate such as "59.94" or "60000/1001", overrides Frequency
+	BitDepth    uint32 `json:"bit_depth,omitempty"`    // Color depth in bits per pixel, the current one when 0
+	Orientation string `json:"orientation,omitempty"`  // 0, 90, 180, 270, landscape, portrait, flipped or portrait-flipped
+	Primary     bool   `json:"primary,omitempty"`      // Make this the primary monitor, at 0,0
+	Enabled     *bool  `json:"enabled,omitempty"`      // false switches the monitor off, monitors that are off are switched on otherwise
+
+	// Optional desktop layout, either an absolute origin or the monitor
+	// (index, ID or name) this one sits next to
+	Position *display.Position `json:"position,omitempty"`
+	RightOf  string            `json:"right_of,omitempty"`
+	LeftOf   string            `json:"left_of,omitempty"`
+	Above    string            `json:"above,omitempty"`
+	Below    string            `json:"below,omitempty"`
+}
+
+// Config represents a display configuration. It either describes a single
+// monitor through the embedded MonitorConfig, or a profile covering several
+// monitors through Monitors, which are all switched in one step.
+type Config struct {
+	Name     string `json:"name"`
+	Topology string `json:"topology,omitempty"` // extend, clone, internal or external, applied before the monitors
+	Persist  *bool  `json:"persist,omitempty"`  // false applies the configuration without storing it as the default
+	MonitorConfig
+	Monitors []MonitorConfig `json:"monitors,omitempty"`
+}
+
+// MonitorConfigs returns the per-monitor settings of the configuration
+func (c *Config) MonitorConfigs() []MonitorConfig {
+	if len(c.Monitors) > 0 {
+		return c.Monitors
+	}
+	// A configuration can consist of a topology only
+	if c.Topology != "" && c.Monitor == 0 && c.MonitorName == "" && c.MonitorID == "" {
+		return nil
+	}
+	return []MonitorConfig{c.MonitorConfig}
+}
+
+// Identifier describes which monitor the settings target, e.g. "(27G2G5)" or "(Monitor 1)"
+func (mc MonitorConfig) Identifier() string {
+	if mc.MonitorID != "" {
+		return fmt.Sprintf("(%s)", mc.MonitorID)
+	} else if mc.MonitorName != "" {
+		return fmt.Sprintf("(%s)", mc.MonitorName)
+	}
+	return fmt.Sprintf("(Monitor %d)", mc.Monitor)
+}
+
+// Rate returns the requested refresh rate, RefreshRate when it is set and Frequency otherwise
+func (mc MonitorConfig) Rate() (display.RefreshRate, error) {
+	if mc.RefreshRate != "" {
+		return display.ParseRefreshRate(mc.RefreshRate)
+	}
+	return display.IntegerRate(mc.Frequency), nil
+}
+
+// Describe summarizes the settings, e.g. "(Monitor 1), 1920x1080 @ 60 Hz right of 2" or "(Monitor 1), off"
+func (mc MonitorConfig) Describe() string {
+	if mc.Enabled != nil && !*mc.Enabled {
+		return fmt.Sprintf("%s, off", mc.Identifier())
+	}
+	description := fmt.Sprintf("%s, %s @ %d Hz", mc.Identifier(), mc.Resolution, mc.Frequency)
+	if mc.RefreshRate != "" {
+		description = fmt.Sprintf("%s, %s @ %s Hz", mc.Identifier(), mc.Resolution, mc.RefreshRate)
+	}
+	if mc.BitDepth != 0 {
+		description += fmt.Sprintf(", %d bit", mc.BitDepth)
+	}
+	if mc.Orientation != "" {
+		description += ", " + mc.Orientation
+	}
+	return description + mc.Layout()
+}
+
+// Layout describes the placement of the monitor, e.g. " at 0,0" or " right of 1 (primary)", or "" when it is not moved
+func (mc MonitorConfig) Layout() string {
+	layout := ""
+	switch {
+	case mc.Position != nil:
+		layout = fmt.Sprintf(" at %d,%d", mc.Position.X, mc.Position.Y)
+	case mc.RightOf != "":
+		layout = " right of " + mc.RightOf
+	case mc.LeftOf != "":
+		layout = " left of " + mc.LeftOf
+	case mc.Above != "":
+		layout = " above " + mc.Above
+	case mc.Below != "":
+		layout = " below " + mc.Below
+	}
+	if mc.Primary {
+		layout += " (primary)"
+	}
+	return layout
+}
+
+// Placement converts the layout settings into a display.Placement, looking up
+// the monitor they refer to in the given monitors, the same list "monitor"
+// indexes into. The placement refers to that monitor by its MonitorID, device
+// names are only known once the monitors are switched on or off. The boolean is
+// false when the monitor is not moved.
+func (mc MonitorConfig) Placement(monitors []display.MonitorInfo) (display.Placement, bool, error) {
+	placement := display.Placement{Position: mc.Position}
+	refs := []struct {
+		identifier string
+		target     *string
+	}{
+		{mc.RightOf, &placement.RightOf},
+		{mc.LeftOf, &placement.LeftOf},
+		{mc.Above, &placement.Above},
+		{mc.Below, &placement.Below},
+	}
+	placed := mc.Position != nil
+	for _, ref := range refs {
+		if ref.identifier == "" {
+			continue
+		}
+		if placed {
+			return placement, false, fmt.Errorf("only one of position, right_of, left_of, above and below can be set")
+		}
+		_, mi, err := display.FindMonitorIn(monitors, ref.identifier)
+		if err != nil {
+			return placement, false, err
+		}
+		*ref.target = mi.MonitorID
+		placed = true
+	}
+	return placement, placed, nil
+}
+
+// FindMonitor returns the monitor targeted by the settings, looked up by ID, friendly name or index
+func (mc MonitorConfig) FindMonitor(monitors []display.MonitorInfo) (display.MonitorInfo, error) {
+	if mc.MonitorID != "" {
+		// Find monitor by stable ID
+		for _, mi := range monitors {
+			if strings.EqualFold(mi.MonitorID, mc.MonitorID) {
+				return mi, nil
+			}
+		}
+		return display.MonitorInfo{}, fmt.Errorf("monitor with ID '%s' not found", mc.MonitorID)
+	}
+	if mc.MonitorName != "" {
+		// Find monitor by friendly name
+		for _, mi := range monitors {
+			if strings.EqualFold(mi.FriendlyName, mc.MonitorName) {
+				return mi, nil
+			}
+		}
+		return display.MonitorInfo{}, fmt.Errorf("monitor with friendly name '%s' not found", mc.MonitorName)
+	}
+	// Find monitor by index
+	if mc.Monitor < 1 || mc.Monitor > len(monitors) {
+		return display.MonitorInfo{}, fmt.Errorf("monitor index %d in configuration is out of range", mc.Monitor)
+	}
+	return monitors[mc.Monitor-1], nil
 }
 
 // Configurations holds a list of Config
 type Configurations struct {
-	Configs []Config `json:"configurations"`
+	Confirm        string   `json:"confirm,omitempty"`         // When to ask before changes: always (default), never or tty-only
+	ConfirmTimeout *int     `json:"confirm_timeout,omitempty"` // Seconds to keep or revert changes, 0 keeps them right away
+	Configs        []Config `json:"configurations"`
 }
 
 // HandleConfigCommand processes the 'config' command with the provided config file path.
@@ -44,13 +200,18 @@ func HandleConfigCommand(args []string, configFile string) {
 		// List configurations
 		fmt.Println("Available configurations:")
 		for i, cfg := range configs.Configs {
-			monitorIdentifier := ""
-			if cfg.MonitorName != "" {
-				monitorIdentifier = fmt.Sprintf("(%s)", cfg.MonitorName)
-			} else {
-				monitorIdentifier = fmt.Sprintf("(Monitor %d)", cfg.Monitor)
+			if cfg.Topology != "" && len(cfg.MonitorConfigs()) == 0 {
+				fmt.Printf("%d. %s: %s topology\n", i+1, cfg.Name, cfg.Topology)
+				continue
+			}
+			if len(cfg.Monitors) == 0 {
+				fmt.Printf("%d. %s: %s\n", i+1, cfg.Name, cfg.Describe())
+				continue
+			}
+			fmt.Printf("%d. %s: %d monitors\n", i+1, cfg.Name, len(cfg.Monitors))
+			for _, mc := range cfg.Monitors {
+				fmt.Printf("     %s\n", mc.Describe())
 			}
-			fmt.Printf("%d. %s: %s, %s @ %d Hz\n", i+1, cfg.Name, monitorIdentifier, cfg.Resolution, cfg.Frequency)
 		}
 	} else {
 		// Apply a configuration
@@ -72,45 +233,213 @@ func HandleConfigCommand(args []string, configFile string) {
 			return
 		}
 
-		// Retrieve the list of monitors
-		monitors, err := display.ListMonitors()
+		applyConfig(cfg)
+	}
+}
+
+// applyConfig switches monitors on or off as the configuration asks, then
+// applies the modes and layout of the monitors that are on in one step
+func applyConfig(cfg *Config) {
+	// A configuration that does not persist leaves the stored modes alone, 'wrm reset' or the end of the session brings them back
+	temporary := cfg.Persist != nil && !*cfg.Persist
+	if temporary {
+		previous := display.Temporary()
+		if err := display.SetTemporary(true); err != nil {
+			fmt.Println("Error applying configuration:", err)
+			return
+		}
+		defer display.SetTemporary(previous)
+	}
+
+	var topology display.Topology
+	if cfg.Topology != "" {
+		var err error
+		topology, err = display.ParseTopology(cfg.Topology)
 		if err != nil {
-			fmt.Println("Error listing monitors:", err)
+			fmt.Println("Error applying configuration:", err)
 			return
 		}
+	}
 
-		var targetMonitor display.MonitorInfo
-		if cfg.MonitorName != "" {
-			// Find monitor by friendly name
-			found := false
-			for _, mi := range monitors {
-				if strings.EqualFold(mi.FriendlyName, cfg.MonitorName) {
-					targetMonitor = mi
-					found = true
-					break
+	// Retrieve the list of monitors, including the ones that are switched off
+	monitors, err := display.ListAllMonitors()
+	if err != nil {
+		fmt.Println("Error listing monitors:", err)
+		return
+	}
+
+	// Find out which monitors to switch on or off and check the settings of
+	// the others before touching anything. The topology decides which monitors
+	// are on, so every configured monitor is switched explicitly after it.
+	toggles := make(map[string]bool)
+	var targets []configTarget
+	primaries := 0
+	for _, mc := range cfg.MonitorConfigs() {
+		targetMonitor, err := mc.FindMonitor(monitors)
+		if err != nil {
+			fmt.Println("Error applying configuration:", err)
+			return
+		}
+		disabled := targetMonitor.Status == display.MONITOR_STATUS_DISABLED
+		if mc.Enabled != nil && !*mc.Enabled {
+			if !disabled || topology != "" {
+				toggles[targetMonitor.MonitorID] = false
+			}
+			continue
+		}
+		if disabled || topology != "" {
+			toggles[targetMonitor.MonitorID] = true
+		}
+		target, err := newConfigTarget(mc, targetMonitor, monitors)
+		if err != nil {
+			fmt.Printf("Error applying configuration to %s: %v\n", targetMonitor.FriendlyName, err)
+			return
+		}
+		if mc.Primary {
+			primaries++
+		}
+		targets = append(targets, target)
+	}
+	if primaries > 1 {
+		fmt.Println("Error applying configuration: only one monitor can be primary")
+		return
+	}
+
+	// Everything is confirmed once and rolled back together when a step fails
+	var names []string
+	resolve := func(pending bool) ([]display.ModeChange, error) {
+		var err error
+		names = nil
+		if !pending {
+			// Indices and device names change when monitors come and go
+			if monitors, err = display.ListMonitors(); err != nil {
+				return nil, fmt.Errorf("error listing monitors: %v", err)
+			}
+		}
+		var changes []display.ModeChange
+		placements := make(map[string]display.Placement)
+		primary := ""
+		for _, target := range targets {
+			targetMonitor, err := MonitorConfig{MonitorID: target.MonitorID}.FindMonitor(monitors)
+			if err != nil {
+				return nil, err
+			}
+			// Monitors that are still off have no mode to test yet
+			if pending && targetMonitor.Status == display.MONITOR_STATUS_DISABLED {
+				if display.DryRun() {
+					fmt.Printf("%s would be switched on, its mode can only be tested once it is on.\n", target.MonitorID)
 				}
+				continue
 			}
-			if !found {
-				fmt.Printf("Monitor with friendly name '%s' not found.\n", cfg.MonitorName)
-				return
+			mode, err := display.SelectMode(targetMonitor.DeviceName, target.Resolution, target.Rate, target.BitDepth, target.Orientation)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", targetMonitor.FriendlyName, err)
 			}
-		} else {
-			// Find monitor by index
-			if cfg.Monitor < 1 || cfg.Monitor > len(monitors) {
-				fmt.Println("Monitor index in configuration is out of range.")
-				return
+			if target.Placed && !pending {
+				if placements[targetMonitor.DeviceName], err = target.devicePlacement(monitors); err != nil {
+					return nil, err
+				}
+			}
+			if target.Primary {
+				primary = targetMonitor.DeviceName
 			}
-			targetMonitor = monitors[cfg.Monitor-1]
+			changes = append(changes, display.ModeChange{DeviceName: targetMonitor.DeviceName, Mode: mode, Orientation: target.Orientation})
+			names = append(names, targetMonitor.FriendlyName)
 		}
+		// The layout depends on which monitors end up on
+		if pending {
+			return changes, nil
+		}
+		// Turn the placements into absolute positions and check the resulting desktop
+		if err := display.ResolveLayout(changes, placements); err != nil {
+			return nil, fmt.Errorf("error in configuration layout: %v", err)
+		}
+		if primary != "" {
+			if changes, err = display.MakePrimary(changes, primary); err != nil {
+				return nil, fmt.Errorf("error in configuration layout: %v", err)
+			}
+		}
+		if changes, err = display.NormalizeLayout(changes); err != nil {
+			return nil, fmt.Errorf("error in configuration layout: %v", err)
+		}
+		return changes, nil
+	}
 
-		// Apply the configuration
-		err = display.SetResolution(targetMonitor.DeviceName, cfg.Resolution, cfg.Frequency)
-		if err != nil {
-			fmt.Println("Error applying configuration:", err)
+	// Apply the configuration
+	err = display.ApplyTransaction(display.Transaction{
+		Reason:   fmt.Sprintf("config '%s'", cfg.Name),
+		Topology: topology,
+		Toggles:  toggles,
+		Resolve:  resolve,
+	})
+	if err == display.ErrCancelled {
+		return
+	} else if err == display.ErrDryRun {
+		fmt.Printf("Configuration '%s' checked, nothing was changed.\n", cfg.Name)
+	} else if err != nil {
+		fmt.Println("Error applying configuration:", err)
+	} else {
+		if len(names) > 0 {
+			fmt.Printf("Configuration '%s' applied successfully to %s.\n", cfg.Name, strings.Join(names, ", "))
 		} else {
-			fmt.Printf("Configuration '%s' applied successfully to %s.\n", cfg.Name, targetMonitor.FriendlyName)
+			fmt.Printf("Configuration '%s' applied successfully.\n", cfg.Name)
+		}
+		if temporary && display.CanReset() {
+			fmt.Println("The configuration is temporary, run 'wrm reset' to return to the stored modes.")
+		} else if temporary {
+			fmt.Println("The configuration is temporary, it lasts until the session ends.")
+		}
+	}
+}
+
+// configTarget is a monitor the configuration keeps on, with its settings checked
+type configTarget struct {
+	MonitorID   string
+	Resolution  string
+	Rate        display.RefreshRate
+	BitDepth    uint32
+	Orientation *display.Orientation
+	Placement   display.Placement // Refers to the other monitors by MonitorID
+	Placed      bool
+	Primary     bool
+}
+
+// newConfigTarget checks the settings of a monitor configuration, placements are
+// looked up in the monitors the configuration was resolved against
+func newConfigTarget(mc MonitorConfig, mi display.MonitorInfo, monitors []display.MonitorInfo) (configTarget, error) {
+	target := configTarget{MonitorID: mi.MonitorID, Resolution: mc.Resolution, BitDepth: mc.BitDepth, Primary: mc.Primary}
+	if mc.Orientation != "" {
+		o, err := display.ParseOrientation(mc.Orientation)
+		if err != nil {
+			return target, err
+		}
+		target.Orientation = &o
+	}
+	var err error
+	if target.Rate, err = mc.Rate(); err != nil {
+		return target, err
+	}
+	if target.Placement, target.Placed, err = mc.Placement(monitors); err != nil {
+		return target, err
+	}
+	return target, nil
+}
+
+// devicePlacement returns the placement with the device names the monitors it
+// refers to have once the topology and toggles are applied
+func (t configTarget) devicePlacement(monitors []display.MonitorInfo) (display.Placement, error) {
+	placement := t.Placement
+	for _, ref := range []*string{&placement.RightOf, &placement.LeftOf, &placement.Above, &placement.Below} {
+		if *ref == "" {
+			continue
+		}
+		mi, err := MonitorConfig{MonitorID: *ref}.FindMonitor(monitors)
+		if err != nil {
+			return placement, fmt.Errorf("monitor %s is placed next to %s which is not active", t.MonitorID, *ref)
 		}
+		*ref = mi.DeviceName
 	}
+	return placement, nil
 }
 
 // EnsureConfigFile checks if the config file exists. If not, it creates one with default configurations.
@@ -129,16 +458,20 @@ func EnsureConfigFile(filename string) error {
 		defaultConfigs := Configurations{
 			Configs: []Config{
 				{
-					Name:       "Gaming Setup",
-					Monitor:    1,
-					Resolution: "1920x1080",
-					Frequency:  180,
+					Name: "Gaming Setup",
+					MonitorConfig: MonitorConfig{
+						Monitor:    1,
+						Resolution: "1920x1080",
+						Frequency:  180,
+					},
 				},
 				{
-					Name:        "Work Setup",
-					MonitorName: monitors[0].FriendlyName,
-					Resolution:  "2560x1440",
-					Frequency:   60,
+					Name: "Work Setup",
+					MonitorConfig: MonitorConfig{
+						MonitorName: monitors[0].FriendlyName,
+						Resolution:  "2560x1440",
+						Frequency:   60,
+					},
 				},
 			},
 		}
@@ -0,0 +1,361 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"windows-resolution-manager/display"
+)
+
+// newFakeDisplays installs a fake backend with a laptop panel and an external
+// monitor to its right, and keeps the state files of the test in a temporary directory
+func newFakeDisplays(t *testing.T) *display.FakeBackend {
+	t.Helper()
+	f := display.NewFakeBackend()
+	f.AddMonitor("Built-in", "eDP-1",
+		display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+		display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32},
+	)
+	f.AddMonitor("27G2G5", "DP-1",
+		display.Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 32},
+		display.Mode{Width: 2560, Height: 1440, Frequency: 144, BitsPerPixel: 32},
+		display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+	)
+	previous := display.CurrentBackend()
+	display.SetBackend(f)
+	display.SetStateDir(t.TempDir())
+	t.Cleanup(func() { display.SetBackend(previous) })
+	return f
+}
+
+// writeConfig writes the configurations to a file in a temporary directory and returns its path
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHandleConfigCommand(t *testing.T) {
+	const configs = `{
+	"configurations": [
+		{"name": "Gaming", "monitor": 2, "resolution": "2560x1440", "frequency": 144},
+		{"name": "By Name", "monitor_name": "27G2G5", "resolution": "1920x1080", "frequency": 60},
+		{"name": "By ID", "monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60},
+		{"name": "Side By Side", "monitors": [
+			{"monitor_id": "FAKE-eDP-1", "resolution": "1280x720", "frequency": 60},
+			{"monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60, "left_of": "1", "primary": true}
+		]},
+		{"name": "External Only", "monitors": [
+			{"monitor_id": "FAKE-eDP-1", "enabled": false},
+			{"monitor_id": "FAKE-DP-1", "resolution": "2560x1440", "frequency": 144}
+		]},
+		{"name": "Laptop Right", "monitors": [
+			{"monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60, "left_of": "1"}
+		]},
+		{"name": "Scaled Laptop", "monitors": [
+			{"monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60, "right_of": "1"}
+		]},
+		{"name": "Dock", "monitors": [
+			{"monitor": 2, "resolution": "1920x1080", "frequency": 60},
+			{"monitor": 1, "resolution": "2560x1440", "frequency": 60, "left_of": "2"}
+		]},
+		{"name": "Projector", "topology": "external"},
+		{"name": "Retro", "persist": false, "monitor": 1, "resolution": "1280x720", "frequency": 60},
+		{"name": "Broken", "monitors": [
+			{"monitor": 1, "resolution": "1280x720", "frequency": 60},
+			{"monitor": 2, "resolution": "800x600", "frequency": 60}
+		]},
+		{"name": "Two Primaries", "monitors": [
+			{"monitor": 1, "resolution": "1280x720", "frequency": 60, "primary": true},
+			{"monitor": 2, "resolution": "1920x1080", "frequency": 60, "primary": true}
+		]}
+	]
+}`
+	type want struct {
+		mode     display.Mode
+		position display.Position
+		disabled bool
+		stored   bool // The mode is also the one returned to by reset
+	}
+	laptop := display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}
+	external := display.Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 32}
+	unchanged := map[string]want{
+		"eDP-1": {mode: laptop, stored: true},
+		"DP-1":  {mode: external, position: display.Position{X: 1920}, stored: true},
+	}
+	tests := []struct {
+		name        string
+		args        []string
+		disabled    []string           // Monitors that are off before the configuration
+		scales      map[string]float64 // Layout scales of the monitors, 1 when missing
+		want        map[string]want
+		wantPrimary string
+	}{
+		{
+			name: "by index",
+			args: []string{"1"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 2560, Height: 1440, Frequency: 144, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "by name",
+			args: []string{"gaming"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 2560, Height: 1440, Frequency: 144, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "monitor by friendly name",
+			args: []string{"By Name"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "monitor by ID",
+			args: []string{"By ID"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "profile with layout and primary",
+			args: []string{"Side By Side"},
+			want: map[string]want{
+				"eDP-1": {mode: display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}, stored: true},
+			},
+			wantPrimary: "DP-1",
+		},
+		{
+			// The fake backend has no primary at the origin, like X11 its desktop starts at 0,0
+			name: "layout shifted to the origin",
+			args: []string{"Laptop Right"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, position: display.Position{X: 1920}, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}, stored: true},
+			},
+		},
+		{
+			// The laptop panel covers half its width on a desktop laid out in logical pixels
+			name:   "placed next to a scaled monitor",
+			args:   []string{"Scaled Laptop"},
+			scales: map[string]float64{"eDP-1": 2},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}, position: display.Position{X: 960}, stored: true},
+			},
+		},
+		{
+			// Monitor 2 is the panel, switched off monitors come last, and it is only placed once it is on
+			name:     "placed next to a monitor switched on",
+			args:     []string{"Dock"},
+			disabled: []string{"eDP-1"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, position: display.Position{X: 2560}, stored: true},
+				"DP-1":  {mode: external, stored: true},
+			},
+		},
+		{
+			name: "monitor switched off",
+			args: []string{"External Only"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, disabled: true, stored: true},
+				"DP-1":  {mode: display.Mode{Width: 2560, Height: 1440, Frequency: 144, BitsPerPixel: 32}, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "topology",
+			args: []string{"Projector"},
+			want: map[string]want{
+				"eDP-1": {mode: laptop, disabled: true, stored: true},
+				"DP-1":  {mode: external, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "not persisted",
+			args: []string{"Retro"},
+			want: map[string]want{
+				"eDP-1": {mode: display.Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32}},
+				"DP-1":  {mode: external, position: display.Position{X: 1920}, stored: true},
+			},
+		},
+		{
+			name: "unsupported mode changes nothing",
+			args: []string{"Broken"},
+			want: unchanged,
+		},
+		{
+			name: "two primary monitors change nothing",
+			args: []string{"Two Primaries"},
+			want: unchanged,
+		},
+		{
+			name: "unknown configuration",
+			args: []string{"Missing"},
+			want: unchanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			for _, device := range tt.disabled {
+				f.Disabled[device] = true
+			}
+			for device, scale := range tt.scales {
+				f.Scales[device] = scale
+			}
+			HandleConfigCommand(tt.args, writeConfig(t, configs))
+
+			for device, w := range tt.want {
+				if got := f.Current[device]; got != w.mode {
+					t.Errorf("%s: mode = %v, want %v", device, got, w.mode)
+				}
+				if got := f.Positions[device]; got != w.position {
+					t.Errorf("%s: position = %v, want %v", device, got, w.position)
+				}
+				if got := f.Disabled[device]; got != w.disabled {
+					t.Errorf("%s: disabled = %v, want %v", device, got, w.disabled)
+				}
+				if stored := f.Stored[device] == w.mode; stored != w.stored {
+					t.Errorf("%s: stored mode = %v, want it stored: %v", device, f.Stored[device], w.stored)
+				}
+			}
+			if f.Primary != tt.wantPrimary {
+				t.Errorf("primary = %q, want %q", f.Primary, tt.wantPrimary)
+			}
+			if display.Temporary() {
+				t.Errorf("changes are still temporary after the configuration")
+			}
+		})
+	}
+}
+
+func TestHandleConfigCommandRollsBack(t *testing.T) {
+	const configs = `{
+	"configurations": [
+		{"name": "External Only", "monitors": [
+			{"monitor_id": "FAKE-eDP-1", "enabled": false},
+			{"monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60}
+		]},
+		{"name": "Docked", "topology": "extend", "monitors": [
+			{"monitor_id": "FAKE-eDP-1", "resolution": "1280x720", "frequency": 60},
+			{"monitor_id": "FAKE-DP-1", "resolution": "1920x1080", "frequency": 60}
+		]}
+	]
+}`
+	laptop := display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}
+	external := display.Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 32}
+	tests := []struct {
+		name         string
+		args         []string
+		laptopOff    bool // The laptop panel is switched off before the configuration
+		answer       bool
+		wantPrompts  int
+		wantLaptop   display.Mode
+		wantExternal display.Mode
+	}{
+		{name: "toggle rolled back", args: []string{"External Only"}, answer: true, wantPrompts: 1, wantLaptop: laptop, wantExternal: external},
+		{name: "topology rolled back", args: []string{"Docked"}, laptopOff: true, answer: true, wantPrompts: 1, wantLaptop: laptop, wantExternal: external},
+		{name: "declined", args: []string{"Docked"}, laptopOff: true, answer: false, wantPrompts: 1, wantLaptop: laptop, wantExternal: external},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			f.Disabled["eDP-1"] = tt.laptopOff
+			// The driver accepts the mode in a test but fails to switch to it
+			f.Broken["DP-1"] = display.Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32}
+			prompts := 0
+			display.SetConfirmHook(func(prompt display.Prompt) bool {
+				prompts++
+				return tt.answer
+			})
+			t.Cleanup(func() { display.SetConfirmHook(nil) })
+
+			HandleConfigCommand(tt.args, writeConfig(t, configs))
+
+			if prompts != tt.wantPrompts {
+				t.Errorf("asked %d times, want %d", prompts, tt.wantPrompts)
+			}
+			if f.Disabled["eDP-1"] != tt.laptopOff {
+				t.Errorf("eDP-1: disabled = %v, want %v", f.Disabled["eDP-1"], tt.laptopOff)
+			}
+			if f.Current["eDP-1"] != tt.wantLaptop {
+				t.Errorf("eDP-1: mode = %v, want %v", f.Current["eDP-1"], tt.wantLaptop)
+			}
+			if f.Current["DP-1"] != tt.wantExternal {
+				t.Errorf("DP-1: mode = %v, want %v", f.Current["DP-1"], tt.wantExternal)
+			}
+			if entry, err := display.UnfinishedChange(); err != nil || entry != nil {
+				t.Errorf("journal left behind: %v, %v", entry, err)
+			}
+		})
+	}
+}
+
+// captureOutput returns what fn prints to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// sessionBackend applies temporary changes but has no stored modes to reset
+// to, like xrandr and wlr-randr
+type sessionBackend struct {
+	display.Backend
+	display.TemporaryApplier
+}
+
+func TestTemporaryConfigHint(t *testing.T) {
+	const configs = `{
+	"configurations": [
+		{"name": "Retro", "persist": false, "monitor": 1, "resolution": "1280x720", "frequency": 60}
+	]
+}`
+	tests := []struct {
+		name    string
+		session bool
+		want    string
+	}{
+		{name: "backend with reset", want: "run 'wrm reset' to return to the stored modes"},
+		{name: "backend without reset", session: true, want: "it lasts until the session ends"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDisplays(t)
+			if tt.session {
+				display.SetBackend(sessionBackend{Backend: f, TemporaryApplier: f})
+			}
+			out := captureOutput(t, func() { HandleConfigCommand([]string{"Retro"}, writeConfig(t, configs)) })
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q does not contain %q", out, tt.want)
+			}
+			if tt.session && strings.Contains(out, "wrm reset") {
+				t.Errorf("output %q offers a reset the backend cannot do", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode describes a single display mode independently of the platform API
+type Mode struct {
+	Width        uint32      `json:"width"`
+	Height       uint32      `json:"height"`
+	Frequency    uint32      `json:"frequency"`      // Refresh rate in Hz
+	Rate         RefreshRate `json:"rate"`           // Exact refresh rate, zero when the backend only reports whole Hz
+	BitsPerPixel uint32      `json:"bits_per_pixel"` // Color depth, 0 when the backend does not report it
+	Interlaced   bool        `json:"interlaced"`
+}
+
+// Resolution formats the size of the mode, e.g. "1920x1080" or "1920x1080i" for interlaced modes
+func (m Mode) Resolution() string {
+	if m.Interlaced {
+		return fmt.Sprintf("%dx%di", m.Width, m.Height)
+	}
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+}
+
+// RefreshRate formats the refresh rate, e.g. "60 Hz" or "59.940 Hz" when the exact rate is known
+func (m Mode) RefreshRate() string {
+	if !m.Rate.IsZero() {
+		return m.Rate.String()
+	}
+	return fmt.Sprintf("%d Hz", m.Frequency)
+}
+
+// String formats the mode as "1920x1080 @ 60 Hz", followed by the color depth when it is known
+func (m Mode) String() string {
+	s := fmt.Sprintf("%s @ %s", m.Resolution(), m.RefreshRate())
+	if m.BitsPerPixel != 0 {
+		s += fmt.Sprintf(", %d bit", m.BitsPerPixel)
+	}
+	return s
+}
+
+// Backend is implemented by every platform specific display driver.
+// All package level helpers (ListMonitors, SetResolution, ...) go through
+// the active backend so the rest of WRM never talks to the OS directly.
+type Backend interface {
+	// Name returns a short identifier of the backend, e.g. "win32"
+	Name() string
+	// ListMonitors enumerates the active monitors
+	ListMonitors() ([]MonitorInfo, error)
+	// ListModes enumerates every mode supported by the device
+	ListModes(deviceName string) ([]Mode, error)
+	// CurrentMode returns the mode the device is currently running
+	CurrentMode(deviceName string) (Mode, error)
+	// TestMode checks whether the device accepts the change without applying it
+	TestMode(change ModeChange) error
+	// ApplyModes switches every device to its mode in a single step
+	ApplyModes(changes []ModeChange) error
+}
+
+var activeBackend Backend
+
+// SetBackend replaces the backend used by the package level helpers
+func SetBackend(b Backend) {
+	activeBackend = b
+}
+
+// CurrentBackend returns the active backend, falling back to the platform default
+func CurrentBackend() Backend {
+	if activeBackend == nil {
+		activeBackend = DefaultBackend()
+	}
+	return activeBackend
+}
+
+// NewBackend returns the backend with the given name, "auto" picks the platform default
+func NewBackend(name string) (Backend, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return DefaultBackend(), nil
+	case "win32", "windows":
+		return newWin32Backend()
+	case "xrandr", "x11":
+		return NewXrandrBackend(), nil
+	case "wlr-randr", "wlroots":
+		return NewWlrRandrBackend(), nil
+	case "mutter", "gnome":
+		return NewMutterBackend(), nil
+	case "kscreen", "kde":
+		return NewKScreenBackend(), nil
+	case "sysfs", "drm":
+		return NewSysfsBackend(), nil
+	}
+	return nil, fmt.Errorf("unknown display backend '%s'", name)
+}
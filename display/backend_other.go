@@ -0,0 +1,68 @@
+//go:build !windows
+
+package display
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// unsupportedBackend is used on platforms without a display backend
+type unsupportedBackend struct{}
+
+func (unsupportedBackend) Name() string {
+	return "unsupported"
+}
+
+func (unsupportedBackend) err() error {
+	return fmt.Errorf("no display backend available on %s", runtime.GOOS)
+}
+
+func (b unsupportedBackend) ListMonitors() ([]MonitorInfo, error) {
+	return nil, b.err()
+}
+
+func (b unsupportedBackend) ListModes(deviceName string) ([]Mode, error) {
+	return nil, b.err()
+}
+
+func (b unsupportedBackend) CurrentMode(deviceName string) (Mode, error) {
+	return Mode{}, b.err()
+}
+
+func (b unsupportedBackend) TestMode(change ModeChange) error {
+	return b.err()
+}
+
+func (b unsupportedBackend) ApplyModes(changes []ModeChange) error {
+	return b.err()
+}
+
+func newWin32Backend() (Backend, error) {
+	return nil, fmt.Errorf("the win32 backend is only available on Windows")
+}
+
+// DefaultBackend returns the backend used when none has been set explicitly,
+// picked from the running session
+func DefaultBackend() Backend {
+	// XWayland also sets DISPLAY, so Wayland sessions have to be checked first
+	if os.Getenv("WAYLAND_DISPLAY") != "" && strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "GNOME") {
+		return NewMutterBackend()
+	}
+	if strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "KDE") && commandExists("kscreen-doctor") {
+		return NewKScreenBackend()
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" && commandExists("wlr-randr") {
+		return NewWlrRandrBackend()
+	}
+	if os.Getenv("DISPLAY") != "" && commandExists("xrandr") {
+		return NewXrandrBackend()
+	}
+	// Without a graphical session we can still report what the kernel sees
+	if sysfs := NewSysfsBackend(); sysfs.available() {
+		return sysfs
+	}
+	return unsupportedBackend{}
+}
@@ -0,0 +1,24 @@
+package display
+
+// win32Backend drives the displays through user32 (CCD and ChangeDisplaySettingsEx)
+type win32Backend struct {
+	temporary bool // Apply changes without storing them in the registry and the display database
+}
+
+// NewWin32Backend returns the user32 based backend
+func NewWin32Backend() Backend {
+	return &win32Backend{}
+}
+
+func newWin32Backend() (Backend, error) {
+	return NewWin32Backend(), nil
+}
+
+func (win32Backend) Name() string {
+	return "win32"
+}
+
+// DefaultBackend returns the backend used when none has been set explicitly
+func DefaultBackend() Backend {
+	return NewWin32Backend()
+}
@@ -0,0 +1,31 @@
+package display
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs an external display tool and returns its standard output.
+// The tool's stderr is included in the error so failures are readable.
+func runCommand(name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(name, args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("%s failed: %v", name, err)
+		}
+		return nil, fmt.Errorf("%s failed: %v: %s", name, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// commandExists reports whether the tool can be found in PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
@@ -0,0 +1,126 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DEFAULT_CONFIRM_TIMEOUT is how long WRM waits for changes to be kept before reverting them
+const DEFAULT_CONFIRM_TIMEOUT = 15 * time.Second
+
+// PENDING_CONFIRM_FILE exists in the state directory while changes wait to be kept, 'wrm confirm' removes it
+const PENDING_CONFIRM_FILE = "confirm.pending"
+
+// ErrReverted is returned when changes were not kept in time and the previous state was restored
+var ErrReverted = errors.New("the changes were not confirmed and have been reverted")
+
+// Prompt describes a change the user is asked to confirm
+type Prompt struct {
+	Question string          // e.g. "Apply these changes?"
+	Topology Topology        // Topology about to be switched to, empty when it is kept
+	Toggles  map[string]bool // Monitors about to be switched on (true) or off, keyed by MonitorID
+	Changes  []ModeChange    // Modes about to be applied
+}
+
+// ConfirmFunc asks the user whether to go ahead with the change of the prompt
+type ConfirmFunc func(prompt Prompt) bool
+
+// KeepFunc blocks until the user keeps the changes just applied, which are
+// reverted after the timeout. It reports false when the user cannot answer,
+// and has to return once done is closed.
+type KeepFunc func(timeout time.Duration, done <-chan struct{}) bool
+
+// The package never talks to the user itself, the cli supplies the hooks.
+// Without them every change is approved, and kept unless 'wrm confirm' is
+// missing when a confirm timeout is set.
+var (
+	confirmHook    ConfirmFunc
+	keepHook       KeepFunc
+	confirmTimeout time.Duration
+)
+
+// SetConfirmHook sets the function asked before every change, nil approves all changes
+func SetConfirmHook(hook ConfirmFunc) {
+	confirmHook = hook
+}
+
+// SetKeepHook sets the function waiting for the user to keep applied changes, nil leaves only 'wrm confirm'
+func SetKeepHook(hook KeepFunc) {
+	keepHook = hook
+}
+
+// SetConfirmTimeout sets how long changes wait to be kept, 0 keeps them without asking
+func SetConfirmTimeout(timeout time.Duration) {
+	confirmTimeout = timeout
+}
+
+// confirm asks the confirmation hook about a change
+func confirm(prompt Prompt) bool {
+	if confirmHook == nil {
+		return true
+	}
+	return confirmHook(prompt)
+}
+
+// KeepOrRevert waits for the user to keep the changes that were just applied,
+// either through the keep hook, Enter on the cli, or by running 'wrm confirm' from another shell,
+// which helps when the new mode leaves the screen black. When nobody confirms
+// in time, the monitors are switched back to the previous snapshot and
+// ErrReverted is returned.
+func KeepOrRevert(previous Snapshot) error {
+	if confirmTimeout <= 0 {
+		return nil
+	}
+	pendingPath := statePath(PENDING_CONFIRM_FILE)
+	if err := ioutil.WriteFile(pendingPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("error writing confirmation file '%s': %v", pendingPath, err)
+	}
+	defer os.Remove(pendingPath)
+
+	keypress := make(chan struct{}, 1)
+	if keepHook != nil {
+		// The hook gives up once the wait is over, whatever the outcome
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			if keepHook(confirmTimeout, done) {
+				keypress <- struct{}{}
+			}
+		}()
+	}
+
+	deadline := time.After(confirmTimeout)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-keypress:
+			return nil
+		case <-ticker.C:
+			if _, err := os.Stat(pendingPath); os.IsNotExist(err) {
+				return nil
+			}
+		case <-deadline:
+			if err := RevertToSnapshot(previous); err != nil {
+				return fmt.Errorf("error reverting changes: %v", err)
+			}
+			return ErrReverted
+		}
+	}
+}
+
+// ConfirmPending keeps the changes another WRM process is waiting on
+func ConfirmPending() error {
+	pendingPath := statePath(PENDING_CONFIRM_FILE)
+	err := os.Remove(pendingPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no changes are waiting for confirmation")
+	} else if err != nil {
+		return fmt.Errorf("error removing confirmation file '%s': %v", pendingPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package display
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withConfirmSettings sets the keep hook and timeout for the test
+func withConfirmSettings(t *testing.T, hook KeepFunc, timeout time.Duration) {
+	t.Helper()
+	SetStateDir(t.TempDir())
+	SetKeepHook(hook)
+	SetConfirmTimeout(timeout)
+	t.Cleanup(func() {
+		SetStateDir("")
+		SetKeepHook(nil)
+		SetConfirmTimeout(0)
+	})
+}
+
+func TestKeepOrRevert(t *testing.T) {
+	tests := []struct {
+		name    string
+		keep    func(done <-chan struct{}) bool
+		confirm bool // Run 'wrm confirm' while waiting
+		want    error
+	}{
+		{"kept with Enter", func(done <-chan struct{}) bool { return true }, false, nil},
+		{"kept with wrm confirm", func(done <-chan struct{}) bool { <-done; return false }, true, nil},
+		{"reverted on timeout", func(done <-chan struct{}) bool { <-done; return false }, false, ErrReverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeBackend()
+			f.AddMonitor("27G2G5", "DISPLAY1", Mode{Width: 1920, Height: 1080, Frequency: 60}, Mode{Width: 1280, Height: 720, Frequency: 60})
+			useBackend(t, f)
+			previous, err := CaptureSnapshot("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Current["DISPLAY1"] = Mode{Width: 1280, Height: 720, Frequency: 60}
+
+			// The hook has to return once the wait is over, whatever ended it
+			returned := make(chan struct{})
+			withConfirmSettings(t, func(timeout time.Duration, done <-chan struct{}) bool {
+				defer close(returned)
+				return tt.keep(done)
+			}, 300*time.Millisecond)
+			if tt.confirm {
+				// The goroutine is joined before the cleanup resets the state directory it reads
+				stop := make(chan struct{})
+				var wg sync.WaitGroup
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for ConfirmPending() != nil {
+						select {
+						case <-stop:
+							return
+						case <-time.After(10 * time.Millisecond):
+						}
+					}
+				}()
+				defer func() {
+					close(stop)
+					wg.Wait()
+				}()
+			}
+
+			if err := KeepOrRevert(previous); err != tt.want {
+				t.Fatalf("KeepOrRevert() = %v, want %v", err, tt.want)
+			}
+			select {
+			case <-returned:
+			case <-time.After(time.Second):
+				t.Fatal("the keep hook is still waiting")
+			}
+			wantMode := Mode{Width: 1280, Height: 720, Frequency: 60}
+			if tt.want == ErrReverted {
+				wantMode = Mode{Width: 1920, Height: 1080, Frequency: 60}
+			}
+			if got := f.Current["DISPLAY1"]; got != wantMode {
+				t.Errorf("mode = %v, want %v", got, wantMode)
+			}
+		})
+	}
+}
+
+func TestStateDirIsPerUser(t *testing.T) {
+	SetStateDir("")
+	dir := StateDir()
+	if !filepath.IsAbs(dir) || filepath.Base(dir) != "wrm" {
+		t.Errorf("StateDir() = %q, want an absolute wrm directory", dir)
+	}
+}
@@ -0,0 +1,58 @@
+package display
+
+import "fmt"
+
+// PrintCurrentModes prints the mode, rotation and position every active monitor
+// is running, or only the one matching the identifier when it is not empty
+func PrintCurrentModes(identifier string) error {
+	monitors, err := ListMonitors()
+	if err != nil {
+		return err
+	}
+	indices := make([]int, 0, len(monitors))
+	if identifier != "" {
+		index, _, err := FindMonitorIn(monitors, identifier)
+		if err != nil {
+			return err
+		}
+		indices = append(indices, index)
+	} else {
+		for i := range monitors {
+			indices = append(indices, i)
+		}
+	}
+
+	for _, i := range indices {
+		mi := monitors[i]
+		line := fmt.Sprintf("%d. %s (%s)", i+1, mi.FriendlyName, mi.DeviceName)
+		if mi.MonitorID != "" {
+			line += " id: " + mi.MonitorID
+		}
+		fmt.Println(line)
+
+		mode, err := CurrentBackend().CurrentMode(mi.DeviceName)
+		if err != nil {
+			return fmt.Errorf("error reading the current mode of %s: %v", mi.DeviceName, err)
+		}
+		orientation, err := CurrentOrientation(mi.DeviceName)
+		if err != nil {
+			return fmt.Errorf("error reading the orientation of %s: %v", mi.DeviceName, err)
+		}
+		line = fmt.Sprintf("   %s, %s", mode, orientation)
+		// Not every backend knows where the monitor sits
+		if pos, err := CurrentPosition(mi.DeviceName); err == nil {
+			line += fmt.Sprintf(" at %d,%d", pos.X, pos.Y)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// isCurrentRate reports whether the listed mode runs at the refresh rate of the
+// current one. Listed modes without an exact rate match on whole Hz.
+func isCurrentRate(mode Mode, current Mode) bool {
+	if mode.Frequency != current.Frequency {
+		return false
+	}
+	return mode.Rate.IsZero() || current.Rate.IsZero() || mode.Rate == current.Rate
+}
@@ -1,81 +1,253 @@
 package display
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
-	"syscall"
-	"unsafe"
 )
 
-var (
-	changeDisplaySettingsExW = user32.NewProc("ChangeDisplaySettingsExW")
-)
+// ErrCancelled is returned when the user declines a change
+var ErrCancelled = errors.New("operation cancelled")
 
-const (
-	CDS_UPDATEREGISTRY = 0x00000001
-	CDS_TEST           = 0x00000002
-)
+// ModeChange is a mode to apply on a device as part of a staged change
+type ModeChange struct {
+	DeviceName  string
+	Mode        Mode
+	Position    *Position    // New desktop origin, nil keeps the current one
+	Orientation *Orientation // New rotation, nil keeps the current one
+	Primary     bool         // Make the device the primary monitor
+}
+
+// Describe formats the target of the change without the device, e.g. "1920x1080 @ 60 Hz, portrait at 1920,0 (primary)"
+func (c ModeChange) Describe() string {
+	s := c.Mode.String()
+	if c.Orientation != nil {
+		s += ", " + c.Orientation.String()
+	}
+	if c.Position != nil {
+		s += fmt.Sprintf(" at %d,%d", c.Position.X, c.Position.Y)
+	}
+	if c.Primary {
+		s += " (primary)"
+	}
+	return s
+}
+
+// ParseResolution parses a resolution written as WidthxHeight. An "i" after
+// the height (1920x1080i) asks for an interlaced mode.
+func ParseResolution(resolution string) (uint32, uint32, bool, error) {
+	resParts := strings.Split(strings.ToLower(resolution), "x")
+	if len(resParts) != 2 {
+		return 0, 0, false, fmt.Errorf("invalid resolution format. Use WidthxHeight (e.g., 1920x1080)")
+	}
+	interlaced := strings.HasSuffix(resParts[1], "i")
+	width, err1 := strconv.Atoi(resParts[0])
+	height, err2 := strconv.Atoi(strings.TrimSuffix(resParts[1], "i"))
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, false, fmt.Errorf("invalid resolution dimensions")
+	}
+	return uint32(width), uint32(height), interlaced, nil
+}
+
+// splitRefreshRate splits a mode written as "1920x1080i@59.94" into its resolution and refresh rate, zero when there is none
+func splitRefreshRate(mode string) (string, RefreshRate, error) {
+	resolution, rate, found := strings.Cut(mode, "@")
+	if !found {
+		return mode, RefreshRate{}, nil
+	}
+	refreshRate, err := ParseRefreshRate(rate)
+	if err != nil {
+		return "", RefreshRate{}, err
+	}
+	return resolution, refreshRate, nil
+}
 
-// ChangeDisplaySettingsEx wraps the Windows API call
-func ChangeDisplaySettingsEx(deviceName *uint16, lpDevMode *DEVMODE, hwnd uintptr, dwflags uint32, lParam uintptr) int32 {
-	ret, _, _ := changeDisplaySettingsExW.Call(
-		uintptr(unsafe.Pointer(deviceName)),
-		uintptr(unsafe.Pointer(lpDevMode)),
-		hwnd,
-		uintptr(dwflags),
-		lParam,
-	)
-	return int32(ret)
+// rateDistance tells how far the refresh rate of the mode is from the requested
+// one, -1 when it does not match. Modes listed in whole Hz match the truncated
+// rate, as Windows lists 59.94 Hz as 59, and a bit less the rounded one.
+func rateDistance(mode Mode, rate RefreshRate) float64 {
+	if !mode.Rate.IsZero() {
+		if (rate.IsInteger() && mode.Frequency == uint32(rate.Hz())) || (!rate.IsInteger() && mode.Rate.Matches(rate)) {
+			return math.Abs(mode.Rate.Hz() - rate.Hz())
+		}
+		return -1
+	}
+	switch {
+	case mode.Frequency == uint32(rate.Hz()):
+		return 0
+	case !rate.IsInteger() && rate.wholeHz(mode.Frequency):
+		return 0.5
+	}
+	return -1
 }
 
-// SetResolution sets the resolution and frequency for a device
-func SetResolution(deviceName string, resolution string, frequency uint32) error {
+// SelectMode picks the mode of the device matching the resolution, refresh
+// rate and color depth. With a zero rate the highest available one is used.
+// With a depth of 0 the current one is kept whenever the mode exists in it, a
+// higher rate at another depth does not change the colors, else the highest wins.
+// Interlaced modes are only picked when asked for, e.g. with "1920x1080i@60";
+// a rate in the resolution is used when the rate argument is zero.
+// Fractional rates such as 59.94 or 60000/1001 pick the closest mode; when the
+// backend only lists whole Hz the exact rate is kept in the mode's Rate.
+// Modes are listed unrotated, so a portrait resolution such as 1440x2560
+// is looked up as 2560x1440 when the orientation is portrait.
+func SelectMode(deviceName string, resolution string, rate RefreshRate, bitsPerPixel uint32, orientation *Orientation) (Mode, error) {
 	modes, err := ListResolutions(deviceName)
 	if err != nil {
-		return err
+		return Mode{}, err
 	}
-	resParts := strings.Split(resolution, "x")
-	if len(resParts) != 2 {
-		return fmt.Errorf("invalid resolution format. Use WidthxHeight (e.g., 1920x1080)")
+	resolution, embeddedRate, err := splitRefreshRate(resolution)
+	if err != nil {
+		return Mode{}, err
 	}
-	width, err1 := strconv.Atoi(resParts[0])
-	height, err2 := strconv.Atoi(resParts[1])
-	if err1 != nil || err2 != nil {
-		return fmt.Errorf("invalid resolution dimensions")
+	if rate.IsZero() {
+		rate = embeddedRate
+	}
+	width, height, interlaced, err := ParseResolution(resolution)
+	if err != nil {
+		return Mode{}, err
+	}
+	if orientation != nil && orientation.SwapsAxes() && width < height {
+		width, height = height, width
+	}
+	var currentDepth uint32
+	if current, err := CurrentBackend().CurrentMode(deviceName); err == nil {
+		currentDepth = current.BitsPerPixel
+	}
+	matches := func(mode Mode) bool {
+		return mode.Width == width && mode.Height == height && mode.Interlaced == interlaced &&
+			(rate.IsZero() || rateDistance(mode, rate) >= 0)
+	}
+	if bitsPerPixel == 0 && currentDepth != 0 {
+		for _, mode := range modes {
+			if matches(mode) && mode.BitsPerPixel == currentDepth {
+				bitsPerPixel = currentDepth
+				break
+			}
+		}
 	}
-	var selectedMode *DEVMODE
+	var selectedMode *Mode
+	var selectedDistance float64
 	for _, mode := range modes {
-		if int(mode.DmPelsWidth) == width && int(mode.DmPelsHeight) == height {
-			if frequency == 0 || mode.DmDisplayFrequency == frequency {
-				if selectedMode == nil || mode.DmDisplayFrequency > selectedMode.DmDisplayFrequency {
-					selectedMode = &mode
-				}
+		if !matches(mode) {
+			continue
+		}
+		if bitsPerPixel != 0 && mode.BitsPerPixel != bitsPerPixel {
+			continue
+		}
+		if rate.IsZero() {
+			if selectedMode == nil || mode.Frequency > selectedMode.Frequency ||
+				(mode.Frequency == selectedMode.Frequency && betterDepth(mode.BitsPerPixel, selectedMode.BitsPerPixel, currentDepth)) {
+				selectedMode = &mode
 			}
+			continue
+		}
+		distance := rateDistance(mode, rate)
+		if distance < 0 {
+			continue
+		}
+		if selectedMode == nil || distance < selectedDistance ||
+			(distance == selectedDistance && betterDepth(mode.BitsPerPixel, selectedMode.BitsPerPixel, currentDepth)) {
+			selectedMode = &mode
+			selectedDistance = distance
 		}
 	}
 	if selectedMode == nil {
-		return fmt.Errorf("resolution %s with frequency %d Hz not available", resolution, frequency)
-	}
-	// Confirm with the user
-	fmt.Printf("Change resolution to %dx%d @ %d Hz? (y/n): ", width, height, selectedMode.DmDisplayFrequency)
-	var response string
-	fmt.Scanln(&response)
-	if strings.ToLower(response) != "y" {
-		fmt.Println("Operation cancelled.")
+		description := resolution
+		if !rate.IsZero() {
+			description += fmt.Sprintf(" with frequency %s", rate)
+		}
+		if bitsPerPixel != 0 {
+			description += fmt.Sprintf(" and %d bit color", bitsPerPixel)
+		}
+		return Mode{}, fmt.Errorf("resolution %s not available", description)
+	}
+	if selectedMode.Rate.IsZero() && !rate.IsZero() && !rate.IsInteger() {
+		selectedMode.Rate = rate
+	}
+	return *selectedMode, nil
+}
+
+// betterDepth reports whether the depth is preferable to the selected one: the current depth wins, then the highest
+func betterDepth(depth uint32, selected uint32, current uint32) bool {
+	if selected == current {
+		return false
+	}
+	return depth == current || depth > selected
+}
+
+// SetResolution sets the resolution, refresh rate and optionally the color depth and orientation for a device
+func SetResolution(deviceName string, resolution string, rate RefreshRate, bitsPerPixel uint32, orientation *Orientation) error {
+	selectedMode, err := SelectMode(deviceName, resolution, rate, bitsPerPixel, orientation)
+	if err != nil {
+		return err
+	}
+	change := ModeChange{DeviceName: deviceName, Mode: selectedMode, Orientation: orientation}
+	err = ApplyTransaction(Transaction{
+		Reason:   fmt.Sprintf("set %s on %s", selectedMode, deviceName),
+		Question: fmt.Sprintf("Change resolution to %s?", change.Describe()),
+		Resolve: func(pending bool) ([]ModeChange, error) {
+			return []ModeChange{change}, nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Resolution changed successfully.")
+	return nil
+}
+
+// SetPrimary makes the device the primary monitor, moving it to 0,0 and
+// shifting every other monitor so the desktop layout is kept
+func SetPrimary(deviceName string) error {
+	return ApplyTransaction(Transaction{
+		Reason:   "primary " + deviceName,
+		Question: fmt.Sprintf("Make %s the primary monitor?", deviceName),
+		Resolve: func(pending bool) ([]ModeChange, error) {
+			mode, err := CurrentBackend().CurrentMode(deviceName)
+			if err != nil {
+				return nil, err
+			}
+			changes, err := MakePrimary([]ModeChange{{DeviceName: deviceName, Mode: mode}}, deviceName)
+			if err != nil {
+				return nil, err
+			}
+			return NormalizeLayout(changes)
+		},
+	})
+}
+
+// ChangeSetTester is implemented by backends that check a set of changes as a
+// whole, where one change can make another valid, e.g. by moving a monitor out
+// of the way of a larger one
+type ChangeSetTester interface {
+	TestModes(changes []ModeChange) error
+}
+
+// testChanges checks the changes with the backend without applying them, in one
+// step when the backend is a ChangeSetTester and one change at a time otherwise
+func testChanges(changes []ModeChange) error {
+	backend := CurrentBackend()
+	if len(changes) == 0 {
 		return nil
 	}
-	// Apply the settings with CDS_TEST flag first to validate
-	deviceNamePtr, _ := syscall.UTF16PtrFromString(deviceName)
-	result := ChangeDisplaySettingsEx(deviceNamePtr, selectedMode, 0, CDS_TEST, 0)
-	if result != 0 {
-		return fmt.Errorf("the requested graphics mode is not supported")
+	if tester, ok := backend.(ChangeSetTester); ok {
+		return tester.TestModes(changes)
 	}
-	// Apply the settings and update the registry
-	result = ChangeDisplaySettingsEx(deviceNamePtr, selectedMode, 0, CDS_UPDATEREGISTRY, 0)
-	if result != 0 {
-		return fmt.Errorf("failed to change display settings")
+	for _, c := range changes {
+		if err := backend.TestMode(c); err != nil {
+			return fmt.Errorf("%s: %v", c.DeviceName, err)
+		}
 	}
-	fmt.Println("Resolution changed successfully and saved to registry.")
 	return nil
 }
+
+// testAndApply validates every change with the backend before applying them all together
+func testAndApply(changes []ModeChange) error {
+	if err := testChanges(changes); err != nil {
+		return err
+	}
+	return CurrentBackend().ApplyModes(changes)
+}
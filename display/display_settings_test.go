@@ -0,0 +1,66 @@
+package display
+
+import "testing"
+
+func TestSelectMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution string
+		rate       RefreshRate
+		depth      uint32
+		want       Mode
+	}{
+		{
+			// 144 Hz only exists in 32 bit, the monitor runs in 16 bit
+			name:       "current depth before a higher rate",
+			resolution: "1920x1080",
+			want:       Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 16},
+		},
+		{
+			name:       "highest rate at the current depth",
+			resolution: "1280x720",
+			want:       Mode{Width: 1280, Height: 720, Frequency: 75, BitsPerPixel: 16},
+		},
+		{
+			name:       "highest depth when the current one is missing",
+			resolution: "2560x1440",
+			want:       Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 32},
+		},
+		{
+			name:       "rate only available at another depth",
+			resolution: "1920x1080",
+			rate:       RefreshRate{Numerator: 144, Denominator: 1},
+			want:       Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+		},
+		{
+			name:       "depth asked for",
+			resolution: "1920x1080",
+			depth:      32,
+			want:       Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeBackend()
+			f.AddMonitor("27G2G5", "DISPLAY1",
+				Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 16},
+				Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+				Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+				Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 16},
+				Mode{Width: 1280, Height: 720, Frequency: 75, BitsPerPixel: 16},
+				Mode{Width: 1280, Height: 720, Frequency: 120, BitsPerPixel: 32},
+				Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 24},
+				Mode{Width: 2560, Height: 1440, Frequency: 60, BitsPerPixel: 32},
+			)
+			useBackend(t, f)
+
+			got, err := SelectMode("DISPLAY1", tt.resolution, tt.rate, tt.depth, nil)
+			if err != nil {
+				t.Fatalf("SelectMode failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SelectMode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package display
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	changeDisplaySettingsExW = user32.NewProc("ChangeDisplaySettingsExW")
+)
+
+const (
+	CDS_UPDATEREGISTRY = 0x00000001
+	CDS_TEST           = 0x00000002
+	CDS_FULLSCREEN     = 0x00000004
+	CDS_SET_PRIMARY    = 0x00000010
+	CDS_NORESET        = 0x10000000
+)
+
+// ChangeDisplaySettingsEx wraps the Windows API call
+func ChangeDisplaySettingsEx(deviceName *uint16, lpDevMode *DEVMODE, hwnd uintptr, dwflags uint32, lParam uintptr) int32 {
+	ret, _, _ := changeDisplaySettingsExW.Call(
+		uintptr(unsafe.Pointer(deviceName)),
+		uintptr(unsafe.Pointer(lpDevMode)),
+		hwnd,
+		uintptr(dwflags),
+		lParam,
+	)
+	return int32(ret)
+}
+
+// devModeForMode builds a DEVMODE for the requested mode on top of the device's current
+// settings. A nil orientation keeps the current one.
+func devModeForMode(deviceName string, mode Mode, orientation *Orientation) (DEVMODE, error) {
+	devMode, err := CurrentDevMode(deviceName)
+	if err != nil {
+		return devMode, err
+	}
+	devMode.DmPelsWidth = mode.Width
+	devMode.DmPelsHeight = mode.Height
+	devMode.DmDisplayFrequency = mode.Frequency
+	devMode.DmFields = DM_PELSWIDTH | DM_PELSHEIGHT | DM_DISPLAYFREQUENCY
+	if mode.Interlaced {
+		devMode.DmDisplayFlags |= DM_INTERLACED
+	} else {
+		devMode.DmDisplayFlags &^= DM_INTERLACED
+	}
+	devMode.DmFields |= DM_DISPLAYFLAGS
+	if mode.BitsPerPixel != 0 {
+		devMode.DmBitsPerPel = mode.BitsPerPixel
+		devMode.DmFields |= DM_BITSPERPEL
+	}
+	if orientation != nil {
+		devMode.DmDisplayOrientation = uint32(*orientation)
+		devMode.DmFields |= DM_DISPLAYORIENTATION
+	}
+	// Rotated monitors take their dimensions swapped
+	if Orientation(devMode.DmDisplayOrientation).SwapsAxes() {
+		devMode.DmPelsWidth, devMode.DmPelsHeight = devMode.DmPelsHeight, devMode.DmPelsWidth
+	}
+	return devMode, nil
+}
+
+// devModeForChange builds the DEVMODE for a change, position included
+func devModeForChange(c ModeChange) (DEVMODE, error) {
+	devMode, err := devModeForMode(c.DeviceName, c.Mode, c.Orientation)
+	if err != nil {
+		return devMode, err
+	}
+	if c.Position != nil {
+		devMode.DmPosition = POINTL{X: c.Position.X, Y: c.Position.Y}
+		devMode.DmFields |= DM_POSITION
+	}
+	return devMode, nil
+}
+
+// TestMode validates the change with the driver using the CDS_TEST flag
+func (win32Backend) TestMode(change ModeChange) error {
+	devMode, err := devModeForChange(change)
+	if err != nil {
+		return err
+	}
+	deviceNamePtr, _ := syscall.UTF16PtrFromString(change.DeviceName)
+	result := ChangeDisplaySettingsEx(deviceNamePtr, &devMode, 0, CDS_TEST, 0)
+	if result != 0 {
+		return fmt.Errorf("the requested graphics mode is not supported")
+	}
+	return nil
+}
+
+// ApplyModes stages every mode in the registry with CDS_NORESET, then
+// commits them all at once so the whole desk switches in a single step.
+// Temporary changes leave the registry alone, CDS_NORESET only works with
+// CDS_UPDATEREGISTRY so every device switches on its own with dynamic flags 0.
+// Fractional refresh rates are set afterwards through the display paths.
+// When a device refuses its mode, the devices staged before it get their
+// previous settings staged again so nothing half done is left behind.
+func (b *win32Backend) ApplyModes(changes []ModeChange) error {
+	flags := uint32(CDS_UPDATEREGISTRY | CDS_NORESET)
+	if b.temporary {
+		flags = 0
+	}
+	var staged []stagedDevice
+	for _, c := range changes {
+		previous, err := b.previousDevMode(c.DeviceName)
+		if err == nil {
+			err = stageChange(c, flags)
+		}
+		if err != nil {
+			return unstage(staged, flags, err)
+		}
+		staged = append(staged, stagedDevice{DeviceName: c.DeviceName, Previous: previous})
+	}
+	// A nil device and mode applies everything staged above, and would undo temporary changes
+	if !b.temporary {
+		result := ChangeDisplaySettingsEx(nil, nil, 0, 0, 0)
+		if result != 0 {
+			return fmt.Errorf("failed to change display settings")
+		}
+	}
+	return applyExactRefreshRates(changes, b.databaseFlags())
+}
+
+// previousDevMode returns the settings a staged device goes back to: the
+// stored ones, or the current ones for temporary changes
+func (b *win32Backend) previousDevMode(deviceName string) (DEVMODE, error) {
+	if b.temporary {
+		return CurrentDevMode(deviceName)
+	}
+	return RegistryDevMode(deviceName)
+}
+
+// stagedDevice remembers the settings of a device staged by ApplyModes
+type stagedDevice struct {
+	DeviceName string
+	Previous   DEVMODE
+}
+
+// stageChange hands the DEVMODE of one change to ChangeDisplaySettingsEx
+func stageChange(c ModeChange, flags uint32) error {
+	devMode, err := devModeForChange(c)
+	if err != nil {
+		return err
+	}
+	return stageDevMode(c.DeviceName, devMode, flags, c.Primary)
+}
+
+// unstage stages the previous settings of the devices already staged, last
+// first, and returns the error that stopped ApplyModes
+func unstage(staged []stagedDevice, flags uint32, cause error) error {
+	for i := len(staged) - 1; i >= 0; i-- {
+		d := staged[i]
+		d.Previous.DmFields |= DM_PELSWIDTH | DM_PELSHEIGHT | DM_DISPLAYFREQUENCY | DM_POSITION
+		// The primary monitor is the one at the origin
+		primary := d.Previous.DmPosition == POINTL{}
+		if err := stageDevMode(d.DeviceName, d.Previous, flags, primary); err != nil {
+			return fmt.Errorf("%v, restoring the previous settings failed too: %v", cause, err)
+		}
+	}
+	return cause
+}
+
+// stageDevMode hands one DEVMODE to ChangeDisplaySettingsEx with the given flags
+func stageDevMode(deviceName string, devMode DEVMODE, flags uint32, primary bool) error {
+	if primary {
+		flags |= CDS_SET_PRIMARY
+	}
+	deviceNamePtr, _ := syscall.UTF16PtrFromString(deviceName)
+	if result := ChangeDisplaySettingsEx(deviceNamePtr, &devMode, 0, flags, 0); result != 0 {
+		return fmt.Errorf("failed to stage display settings for %s", deviceName)
+	}
+	return nil
+}
+
+// PrimaryAtOrigin marks Windows as keeping its primary monitor at 0,0
+func (win32Backend) PrimaryAtOrigin() {}
+
+// SetTemporary makes the following changes skip the registry and the display database
+func (b *win32Backend) SetTemporary(temporary bool) {
+	b.temporary = temporary
+}
+
+// databaseFlags returns the SetDisplayConfig flags that store a change, none for temporary changes
+func (b *win32Backend) databaseFlags() uint32 {
+	if b.temporary {
+		return 0
+	}
+	return SDC_SAVE_TO_DATABASE
+}
+
+// ResetModes applies the configuration stored in the display database, which
+// brings back monitors switched off temporarily, then the modes stored in the
+// registry: a nil device and mode returns every device to its registry settings.
+func (win32Backend) ResetModes() error {
+	ret := SetDisplayConfig(0, nil, 0, nil, SDC_APPLY|SDC_USE_DATABASE_CURRENT)
+	if ret != ERROR_SUCCESS {
+		return fmt.Errorf("SetDisplayConfig failed with error %d", ret)
+	}
+	result := ChangeDisplaySettingsEx(nil, nil, 0, 0, 0)
+	if result != 0 {
+		return fmt.Errorf("failed to reset display settings")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package display
+
+import (
+	"fmt"
+	"windows-resolution-manager/edid"
+)
+
+// EDIDReader is implemented by backends that can read the raw EDID of a monitor
+type EDIDReader interface {
+	ReadEDID(deviceName string) ([]byte, error)
+}
+
+// ReadEDID returns the raw EDID of a device through the current backend
+func ReadEDID(deviceName string) ([]byte, error) {
+	backend := CurrentBackend()
+	reader, ok := backend.(EDIDReader)
+	if !ok {
+		return nil, fmt.Errorf("the %s backend cannot read EDID", backend.Name())
+	}
+	return reader.ReadEDID(deviceName)
+}
+
+// edidMonitorID builds the stable ID of a monitor from its EDID ids and the connector
+// it is plugged into, e.g. "AOC-2702-DP-1". Without a valid EDID the connector is used.
+func edidMonitorID(raw []byte, connector string) string {
+	e, err := edid.Parse(raw)
+	if err != nil {
+		return connector
+	}
+	return makeMonitorID(e.Manufacturer, fmt.Sprintf("%04X", e.ProductCode), connector)
+}
+
+// edidMonitorName returns the monitor name descriptor of an EDID, or "" if it cannot be decoded
+func edidMonitorName(raw []byte) string {
+	e, err := edid.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return e.MonitorName
+}
@@ -0,0 +1,70 @@
+package display
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeTestEDID builds a minimal EDID 1.4 base block with the manufacturer,
+// product code and monitor name, e.g. makeTestEDID("AOC", 0x2702, "27G2G5")
+func makeTestEDID(manufacturer string, product uint16, name string) []byte {
+	raw := make([]byte, 128)
+	copy(raw, []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00})
+	id := uint16(manufacturer[0]-'A'+1)<<10 | uint16(manufacturer[1]-'A'+1)<<5 | uint16(manufacturer[2]-'A'+1)
+	raw[8], raw[9] = byte(id>>8), byte(id)
+	raw[10], raw[11] = byte(product), byte(product>>8)
+	raw[18], raw[19] = 1, 4
+	raw[20] = 0x80
+	// Unused standard timings
+	for i := 38; i < 54; i++ {
+		raw[i] = 0x01
+	}
+	// The monitor name descriptor, padded with spaces after the line feed
+	descriptor := raw[54:72]
+	descriptor[3] = 0xFC
+	text := []byte(name + "\n            ")[:13]
+	copy(descriptor[5:], text)
+	var sum byte
+	for _, b := range raw[:127] {
+		sum += b
+	}
+	raw[127] = -sum
+	return raw
+}
+
+// writeTestConnector creates a DRM connector directory below root with the given attributes
+func writeTestConnector(t *testing.T, root string, name string, attributes map[string][]byte) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for attribute, content := range attributes {
+		if err := os.WriteFile(filepath.Join(dir, attribute), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEDIDMonitorID(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{name: "valid EDID", raw: makeTestEDID("AOC", 0x2702, "27G2G5"), want: "AOC-2702-DP-1"},
+		{name: "no EDID", want: "DP-1"},
+		{name: "broken EDID", raw: make([]byte, 128), want: "DP-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := edidMonitorID(tt.raw, "DP-1"); got != tt.want {
+				t.Errorf("edidMonitorID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := edidMonitorName(makeTestEDID("AOC", 0x2702, "27G2G5")); got != "27G2G5" {
+		t.Errorf("edidMonitorName = %q, want %q", got, "27G2G5")
+	}
+}
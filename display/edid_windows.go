@@ -0,0 +1,46 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// ReadEDID reads the EDID Windows caches in the registry for the monitor
+// driven by the device. The monitor device path, e.g.
+// \\?\DISPLAY#AOC2702#5&1a2b3c4d&0&UID4352#{e6f07b5f-...}, maps to the key
+// HKLM\SYSTEM\CurrentControlSet\Enum\DISPLAY\AOC2702\5&1a2b3c4d&0&UID4352.
+func (b win32Backend) ReadEDID(deviceName string) ([]byte, error) {
+	monitors, err := b.ListMonitors()
+	if err != nil {
+		return nil, err
+	}
+	devicePath := ""
+	for _, mi := range monitors {
+		if strings.EqualFold(mi.DeviceName, deviceName) {
+			devicePath = mi.DevicePath
+			break
+		}
+	}
+	if devicePath == "" {
+		return nil, fmt.Errorf("no monitor device path for %s", deviceName)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(devicePath, `\\?\`), "#")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("unexpected monitor device path %s", devicePath)
+	}
+	keyPath := `SYSTEM\CurrentControlSet\Enum\` + parts[0] + `\` + parts[1] + `\` + parts[2] + `\Device Parameters`
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, keyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("could not open registry key %s: %v", keyPath, err)
+	}
+	defer key.Close()
+
+	data, _, err := key.GetBinaryValue("EDID")
+	if err != nil {
+		return nil, fmt.Errorf("could not read EDID from registry: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,73 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MONITOR_STATUS_DISABLED is the Status of connected monitors that are switched off
+const MONITOR_STATUS_DISABLED = "disabled"
+
+// MonitorToggler is implemented by backends that can switch monitors on and off
+type MonitorToggler interface {
+	// ListAllMonitors returns the active monitors followed by the connected
+	// ones that are switched off, which have their Status set to "disabled"
+	ListAllMonitors() ([]MonitorInfo, error)
+	// SetEnabled switches the monitors, keyed by MonitorID, on or off in one step
+	SetEnabled(states map[string]bool) error
+}
+
+// ListAllMonitors returns every monitor including the disabled ones when the
+// backend can report them. Active monitors come first so their indices match
+// the ones of ListMonitors.
+func ListAllMonitors() ([]MonitorInfo, error) {
+	if toggler, ok := CurrentBackend().(MonitorToggler); ok {
+		return toggler.ListAllMonitors()
+	}
+	return ListMonitors()
+}
+
+// FindAnyMonitor is FindMonitor including the monitors that are switched off
+func FindAnyMonitor(identifier string) (int, MonitorInfo, error) {
+	monitors, err := ListAllMonitors()
+	if err != nil {
+		return -1, MonitorInfo{}, fmt.Errorf("error listing monitors: %v", err)
+	}
+	return FindMonitorIn(monitors, identifier)
+}
+
+// SetMonitorsEnabled switches monitors, keyed by MonitorID, on or off after
+// asking the user. Like a mode change it is rolled back when it fails and waits to be kept.
+func SetMonitorsEnabled(states map[string]bool) error {
+	if len(states) == 0 {
+		return fmt.Errorf("no changes to apply")
+	}
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		if states[id] {
+			ids = append(ids, id+" on")
+		} else {
+			ids = append(ids, id+" off")
+		}
+	}
+	sort.Strings(ids)
+	return ApplyTransaction(Transaction{Reason: "switching " + strings.Join(ids, ", "), Toggles: states})
+}
+
+// printToggles lists the monitors to switch under the heading, sorted by MonitorID
+func printToggles(heading string, states map[string]bool) {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fmt.Println(heading)
+	for _, id := range ids {
+		if states[id] {
+			fmt.Printf("  %s: on\n", id)
+		} else {
+			fmt.Printf("  %s: off\n", id)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package display
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procSetDisplayConfig = user32.NewProc("SetDisplayConfig")
+)
+
+const (
+	QDC_ALL_PATHS = 0x00000001
+
+	DISPLAYCONFIG_PATH_ACTIVE           = 0x00000001
+	DISPLAYCONFIG_PATH_MODE_IDX_INVALID = 0xFFFFFFFF
+
+	SDC_USE_SUPPLIED_DISPLAY_CONFIG = 0x00000020
+	SDC_APPLY                       = 0x00000080
+	SDC_SAVE_TO_DATABASE            = 0x00000200
+	SDC_ALLOW_CHANGES               = 0x00000400
+)
+
+func SetDisplayConfig(numPathArrayElements uint32, pathArray *DISPLAYCONFIG_PATH_INFO, numModeInfoArrayElements uint32, modeInfoArray *DISPLAYCONFIG_MODE_INFO, flags uint32) int32 {
+	ret, _, _ := procSetDisplayConfig.Call(
+		uintptr(numPathArrayElements),
+		uintptr(unsafe.Pointer(pathArray)),
+		uintptr(numModeInfoArrayElements),
+		uintptr(unsafe.Pointer(modeInfoArray)),
+		uintptr(flags),
+	)
+	return int32(ret)
+}
+
+// win32Target identifies a target (monitor connector) of an adapter
+type win32Target struct {
+	AdapterId LUID
+	Id        uint32
+}
+
+// win32TargetIDs returns the stable monitor ID of every available target of the paths
+func win32TargetIDs(paths []DISPLAYCONFIG_PATH_INFO) map[win32Target]string {
+	ids := make(map[win32Target]string)
+	for _, path := range paths {
+		target := win32Target{path.TargetInfo.AdapterId, path.TargetInfo.Id}
+		if _, ok := ids[target]; ok || path.TargetInfo.TargetAvailable == 0 {
+			continue
+		}
+		targetName, err := getTargetDeviceName(target.AdapterId, target.Id)
+		if err != nil {
+			continue
+		}
+		ids[target] = win32MonitorID(&targetName)
+	}
+	return ids
+}
+
+// ListAllMonitors returns the active monitors followed by the connected targets without an active path
+func (b win32Backend) ListAllMonitors() ([]MonitorInfo, error) {
+	monitors, err := b.ListMonitors()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool)
+	for _, mi := range monitors {
+		active[mi.MonitorID] = true
+	}
+
+	paths, _, err := queryDisplayConfig(QDC_ALL_PATHS)
+	if err != nil {
+		return nil, err
+	}
+	// QDC_ALL_PATHS returns every source and target combination, keep one entry per target
+	for _, path := range paths {
+		if path.TargetInfo.TargetAvailable == 0 {
+			continue
+		}
+		targetName, err := getTargetDeviceName(path.TargetInfo.AdapterId, path.TargetInfo.Id)
+		if err != nil {
+			continue
+		}
+		monitorID := win32MonitorID(&targetName)
+		if active[monitorID] {
+			continue
+		}
+		active[monitorID] = true
+		monitors = append(monitors, MonitorInfo{
+			AdapterId:    path.TargetInfo.AdapterId,
+			Id:           path.TargetInfo.Id,
+			FriendlyName: targetFriendlyName(&targetName),
+			DevicePath:   syscall.UTF16ToString(targetName.MonitorDevicePath[:]),
+			MonitorID:    monitorID,
+			Status:       MONITOR_STATUS_DISABLED,
+		})
+	}
+	return monitors, nil
+}
+
+// SetEnabled rebuilds the active paths without the monitors to switch off and
+// with a new path for every monitor to switch on, then applies them with
+// SetDisplayConfig. Windows picks the mode and position of new paths.
+func (b *win32Backend) SetEnabled(states map[string]bool) error {
+	paths, modes, err := queryDisplayConfig(QDC_ALL_PATHS)
+	if err != nil {
+		return err
+	}
+	ids := win32TargetIDs(paths)
+
+	var newPaths []DISPLAYCONFIG_PATH_INFO
+	usedSources := make(map[win32Target]bool)
+	enabled := make(map[string]bool)
+	for _, path := range paths {
+		if path.Flags&DISPLAYCONFIG_PATH_ACTIVE == 0 {
+			continue
+		}
+		monitorID := ids[win32Target{path.TargetInfo.AdapterId, path.TargetInfo.Id}]
+		if on, ok := states[monitorID]; ok && !on {
+			continue
+		}
+		newPaths = append(newPaths, path)
+		usedSources[win32Target{path.SourceInfo.AdapterId, path.SourceInfo.Id}] = true
+		enabled[monitorID] = true
+	}
+
+	for monitorID, on := range states {
+		if !on || enabled[monitorID] {
+			continue
+		}
+		// Any path of the target whose source is still free will do
+		found := false
+		for _, path := range paths {
+			source := win32Target{path.SourceInfo.AdapterId, path.SourceInfo.Id}
+			if ids[win32Target{path.TargetInfo.AdapterId, path.TargetInfo.Id}] != monitorID || usedSources[source] {
+				continue
+			}
+			path.Flags |= DISPLAYCONFIG_PATH_ACTIVE
+			path.SourceInfo.ModeInfoIdx = DISPLAYCONFIG_PATH_MODE_IDX_INVALID
+			path.TargetInfo.ModeInfoIdx = DISPLAYCONFIG_PATH_MODE_IDX_INVALID
+			newPaths = append(newPaths, path)
+			usedSources[source] = true
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("monitor %s is not connected or has no free display source", monitorID)
+		}
+	}
+	if len(newPaths) == 0 {
+		return fmt.Errorf("at least one monitor has to stay enabled")
+	}
+
+	var modePtr *DISPLAYCONFIG_MODE_INFO
+	if len(modes) > 0 {
+		modePtr = &modes[0]
+	}
+	ret := SetDisplayConfig(uint32(len(newPaths)), &newPaths[0], uint32(len(modes)), modePtr,
+		SDC_APPLY|SDC_USE_SUPPLIED_DISPLAY_CONFIG|SDC_ALLOW_CHANGES|b.databaseFlags())
+	if ret != ERROR_SUCCESS {
+		return fmt.Errorf("SetDisplayConfig failed with error %d", ret)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package display
+
+import "fmt"
+
+// FakeBackend is an in-memory Backend, used to exercise the cmd and config
+// packages on machines without real displays (e.g. Linux CI)
+type FakeBackend struct {
+	Monitors     []MonitorInfo
+	Modes        map[string][]Mode      // Supported modes keyed by device name
+	Current      map[string]Mode        // Active mode keyed by device name
+	EDIDs        map[string][]byte      // Raw EDID keyed by device name
+	Positions    map[string]Position    // Desktop origin keyed by device name
+	Orientations map[string]Orientation // Rotation keyed by device name, landscape when missing
+	Scales       map[string]float64     // Layout scale keyed by device name, 1 when missing
+	Primary      string                 // Device name of the primary monitor
+	Disabled     map[string]bool        // Monitors that are switched off, keyed by device name
+	Applied      [][]ModeChange         // Every successful ApplyModes call, in order
+	Temporary    bool                   // Changes are not stored, ResetModes undoes them
+	Stored       map[string]Mode        // Mode ResetModes returns to, keyed by device name
+	Broken       map[string]Mode        // Mode that passes TestMode but fails in ApplyModes, keyed by device name
+}
+
+// NewFakeBackend creates an empty FakeBackend
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		Modes:        make(map[string][]Mode),
+		Current:      make(map[string]Mode),
+		EDIDs:        make(map[string][]byte),
+		Positions:    make(map[string]Position),
+		Orientations: make(map[string]Orientation),
+		Scales:       make(map[string]float64),
+		Disabled:     make(map[string]bool),
+		Stored:       make(map[string]Mode),
+		Broken:       make(map[string]Mode),
+	}
+}
+
+// AddMonitor registers a monitor with its supported modes; the first mode becomes the current one
+func (f *FakeBackend) AddMonitor(friendlyName string, deviceName string, modes ...Mode) {
+	f.Monitors = append(f.Monitors, MonitorInfo{
+		Id:           uint32(len(f.Monitors)),
+		FriendlyName: friendlyName,
+		DeviceName:   deviceName,
+		MonitorID:    makeMonitorID("FAKE", deviceName),
+	})
+	f.Modes[deviceName] = modes
+	if len(modes) > 0 {
+		f.Current[deviceName] = modes[0]
+		f.Stored[deviceName] = modes[0]
+	}
+	// Monitors are lined up from left to right in the order they are added
+	var x int32
+	for _, mi := range f.Monitors[:len(f.Monitors)-1] {
+		x += int32(f.Current[mi.DeviceName].Width)
+	}
+	f.Positions[deviceName] = Position{X: x}
+}
+
+func (f *FakeBackend) Name() string {
+	return "fake"
+}
+
+func (f *FakeBackend) ListMonitors() ([]MonitorInfo, error) {
+	var monitors []MonitorInfo
+	for _, mi := range f.Monitors {
+		if !f.Disabled[mi.DeviceName] {
+			monitors = append(monitors, mi)
+		}
+	}
+	return monitors, nil
+}
+
+func (f *FakeBackend) ListAllMonitors() ([]MonitorInfo, error) {
+	monitors, _ := f.ListMonitors()
+	for _, mi := range f.Monitors {
+		if f.Disabled[mi.DeviceName] {
+			mi.Status = MONITOR_STATUS_DISABLED
+			monitors = append(monitors, mi)
+		}
+	}
+	return monitors, nil
+}
+
+func (f *FakeBackend) SetEnabled(states map[string]bool) error {
+	for _, mi := range f.Monitors {
+		if on, ok := states[mi.MonitorID]; ok {
+			f.Disabled[mi.DeviceName] = !on
+		}
+	}
+	return nil
+}
+
+func (f *FakeBackend) ListModes(deviceName string) ([]Mode, error) {
+	modes, ok := f.Modes[deviceName]
+	if !ok {
+		return nil, fmt.Errorf("unknown device %s", deviceName)
+	}
+	return modes, nil
+}
+
+func (f *FakeBackend) CurrentMode(deviceName string) (Mode, error) {
+	mode, ok := f.Current[deviceName]
+	if !ok {
+		return Mode{}, fmt.Errorf("unknown device %s", deviceName)
+	}
+	return mode, nil
+}
+
+func (f *FakeBackend) CurrentPosition(deviceName string) (Position, error) {
+	pos, ok := f.Positions[deviceName]
+	if !ok {
+		return Position{}, fmt.Errorf("unknown device %s", deviceName)
+	}
+	return pos, nil
+}
+
+func (f *FakeBackend) CurrentOrientation(deviceName string) (Orientation, error) {
+	if _, ok := f.Modes[deviceName]; !ok {
+		return OrientationLandscape, fmt.Errorf("unknown device %s", deviceName)
+	}
+	return f.Orientations[deviceName], nil
+}
+
+func (f *FakeBackend) LayoutScale(deviceName string, mode Mode) (float64, error) {
+	if _, ok := f.Modes[deviceName]; !ok {
+		return 0, fmt.Errorf("unknown device %s", deviceName)
+	}
+	if scale, ok := f.Scales[deviceName]; ok {
+		return scale, nil
+	}
+	return 1, nil
+}
+
+func (f *FakeBackend) ReadEDID(deviceName string) ([]byte, error) {
+	data, ok := f.EDIDs[deviceName]
+	if !ok {
+		return nil, fmt.Errorf("device %s has no EDID", deviceName)
+	}
+	return data, nil
+}
+
+func (f *FakeBackend) TestMode(change ModeChange) error {
+	mode := change.Mode
+	modes, err := f.ListModes(change.DeviceName)
+	if err != nil {
+		return err
+	}
+	// Modes listed in whole Hz accept any exact rate, like the win32 backend
+	wholeHz := mode
+	wholeHz.Rate = RefreshRate{}
+	for _, m := range modes {
+		if m == mode || (m.Rate.IsZero() && m == wholeHz) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the requested graphics mode is not supported")
+}
+
+// ApplyModes applies all changes, or none of them if one is not supported
+func (f *FakeBackend) ApplyModes(changes []ModeChange) error {
+	for _, c := range changes {
+		if err := f.TestMode(c); err != nil {
+			return err
+		}
+		if broken, ok := f.Broken[c.DeviceName]; ok && broken == c.Mode {
+			return fmt.Errorf("failed to change display settings for %s", c.DeviceName)
+		}
+	}
+	for _, c := range changes {
+		f.Current[c.DeviceName] = c.Mode
+		if !f.Temporary {
+			f.Stored[c.DeviceName] = c.Mode
+		}
+		if c.Position != nil {
+			f.Positions[c.DeviceName] = *c.Position
+		}
+		if c.Orientation != nil {
+			f.Orientations[c.DeviceName] = *c.Orientation
+		}
+		if c.Primary {
+			f.Primary = c.DeviceName
+		}
+	}
+	f.Applied = append(f.Applied, changes)
+	return nil
+}
+
+func (f *FakeBackend) SetTemporary(temporary bool) {
+	f.Temporary = temporary
+}
+
+// ResetModes returns every device to its stored mode
+func (f *FakeBackend) ResetModes() error {
+	for deviceName, mode := range f.Stored {
+		f.Current[deviceName] = mode
+	}
+	return nil
+}
@@ -27,29 +27,47 @@ func ListFrequenciesForResolution(monitorIndex int, resolution string) {
 		fmt.Println("Error listing frequencies:", err)
 		return
 	}
-	resParts := strings.Split(resolution, "x")
-	if len(resParts) != 2 {
-		fmt.Println("Invalid resolution format. Use WidthxHeight (e.g., 1920x1080)")
-		return
-	}
-	width, err1 := strconv.Atoi(resParts[0])
-	height, err2 := strconv.Atoi(resParts[1])
-	if err1 != nil || err2 != nil {
-		fmt.Println("Invalid resolution dimensions")
+	width, height, interlaced, err := ParseResolution(resolution)
+	if err != nil {
+		fmt.Println("Error:", err)
 		return
 	}
-	fmt.Printf("Frequencies for %dx%d on %s:\n", width, height, mi.FriendlyName)
-	freqMap := make(map[uint32]bool)
-	count := 1
+	// Not every backend knows the current mode, the listing then goes without the marker
+	current, err := CurrentBackend().CurrentMode(deviceName)
+	knowsCurrent := err == nil
+	fmt.Printf("Frequencies for %s on %s:\n", Mode{Width: width, Height: height, Interlaced: interlaced}.Resolution(), mi.FriendlyName)
+	// Collect the color depths of every refresh rate, in the order the modes are listed.
+	// Exact rates keep 59.94 Hz and 60 Hz apart when the backend reports them.
+	var freqs []string
+	depthMap := make(map[string][]string)
+	seenDepths := make(map[string]bool)
+	currentFreq := ""
 	for _, mode := range modes {
-		if int(mode.DmPelsWidth) == width && int(mode.DmPelsHeight) == height {
-			freq := mode.DmDisplayFrequency
-			if !freqMap[freq] {
-				fmt.Printf("%d. %d Hz\n", count, freq)
-				freqMap[freq] = true
-				count++
+		if mode.Width == width && mode.Height == height && mode.Interlaced == interlaced {
+			freq := mode.RefreshRate()
+			if knowsCurrent && mode.Interlaced == current.Interlaced && mode.Width == current.Width && mode.Height == current.Height && isCurrentRate(mode, current) {
+				currentFreq = freq
+			}
+			if _, ok := depthMap[freq]; !ok {
+				freqs = append(freqs, freq)
+				depthMap[freq] = []string{}
 			}
+			depth := strconv.Itoa(int(mode.BitsPerPixel))
+			if mode.BitsPerPixel != 0 && !seenDepths[fmt.Sprintf("%s-%s", freq, depth)] {
+				depthMap[freq] = append(depthMap[freq], depth)
+				seenDepths[fmt.Sprintf("%s-%s", freq, depth)] = true
+			}
+		}
+	}
+	for i, freq := range freqs {
+		line := fmt.Sprintf("%d. %s", i+1, freq)
+		if len(depthMap[freq]) > 0 {
+			line += fmt.Sprintf(" (%s bit)", strings.Join(depthMap[freq], ", "))
 		}
+		if freq == currentFreq {
+			line += " (current)"
+		}
+		fmt.Println(line)
 	}
 }
 
@@ -59,17 +77,13 @@ func ValidateFrequency(deviceName string, resolution string, frequency uint32) (
 	if err != nil {
 		return false, err
 	}
-	resParts := strings.Split(resolution, "x")
-	if len(resParts) != 2 {
-		return false, fmt.Errorf("invalid resolution format")
-	}
-	width, err1 := strconv.Atoi(resParts[0])
-	height, err2 := strconv.Atoi(resParts[1])
-	if err1 != nil || err2 != nil {
-		return false, fmt.Errorf("invalid resolution dimensions")
+	width, height, interlaced, err := ParseResolution(resolution)
+	if err != nil {
+		return false, err
 	}
+	// Interlaced modes only count when the resolution asks for them
 	for _, mode := range modes {
-		if int(mode.DmPelsWidth) == width && int(mode.DmPelsHeight) == height && mode.DmDisplayFrequency == frequency {
+		if mode.Width == width && mode.Height == height && mode.Interlaced == interlaced && mode.Frequency == frequency {
 			return true, nil
 		}
 	}
@@ -82,20 +96,16 @@ func GetHighestFrequency(deviceName string, resolution string) (uint32, error) {
 	if err != nil {
 		return 0, err
 	}
-	resParts := strings.Split(resolution, "x")
-	if len(resParts) != 2 {
-		return 0, fmt.Errorf("invalid resolution format")
-	}
-	width, err1 := strconv.Atoi(resParts[0])
-	height, err2 := strconv.Atoi(resParts[1])
-	if err1 != nil || err2 != nil {
-		return 0, fmt.Errorf("invalid resolution dimensions")
+	width, height, interlaced, err := ParseResolution(resolution)
+	if err != nil {
+		return 0, err
 	}
+	// Interlaced modes only count when the resolution asks for them
 	var highestFreq uint32
 	for _, mode := range modes {
-		if int(mode.DmPelsWidth) == width && int(mode.DmPelsHeight) == height {
-			if mode.DmDisplayFrequency > highestFreq {
-				highestFreq = mode.DmDisplayFrequency
+		if mode.Width == width && mode.Height == height && mode.Interlaced == interlaced {
+			if mode.Frequency > highestFreq {
+				highestFreq = mode.Frequency
 			}
 		}
 	}
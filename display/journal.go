@@ -0,0 +1,79 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// JOURNAL_FILE is kept in the state directory while a change is in progress
+const JOURNAL_FILE = "journal.json"
+
+// JournalEntry records a change in progress, so the previous state can be
+// recovered when WRM or the machine dies before the change is kept
+type JournalEntry struct {
+	PID      int       `json:"pid"`
+	Start    string    `json:"start,omitempty"` // Identifies the process beyond its PID, which is reused after a reboot
+	Time     time.Time `json:"time"`
+	Previous Snapshot  `json:"previous"`
+}
+
+// BeginChange writes the journal entry for a change about to be applied
+func BeginChange(previous Snapshot) error {
+	entry := JournalEntry{PID: os.Getpid(), Time: time.Now(), Previous: previous}
+	// Without a start the entry is taken as unfinished by any other WRM, which is safer than ignoring it
+	entry.Start, _ = processStart(entry.PID)
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling journal: %v", err)
+	}
+	journalPath := statePath(JOURNAL_FILE)
+	if err := ioutil.WriteFile(journalPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing journal '%s': %v", journalPath, err)
+	}
+	return nil
+}
+
+// EndChange clears the journal once a change was kept, reverted or abandoned
+func EndChange() error {
+	err := os.Remove(statePath(JOURNAL_FILE))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing journal: %v", err)
+	}
+	return nil
+}
+
+// UnfinishedChange returns the journal entry left behind by a WRM that did not
+// finish its change, or nil. Entries of a WRM that is still running, e.g. one
+// waiting for 'wrm confirm', are not unfinished. A running process only counts
+// when its start matches too: after a crash and reboot the PID often belongs
+// to an unrelated process.
+func UnfinishedChange() (*JournalEntry, error) {
+	journalPath := statePath(JOURNAL_FILE)
+	data, err := ioutil.ReadFile(journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read journal '%s': %v", journalPath, err)
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid JSON in journal '%s': %v", journalPath, err)
+	}
+	if entry.PID > 0 && entry.PID != os.Getpid() && entry.Start != "" && processAlive(entry.PID) {
+		if start, err := processStart(entry.PID); err == nil && start == entry.Start {
+			return nil, nil
+		}
+	}
+	return &entry, nil
+}
+
+// RecoverChange switches back to the state from before the unfinished change and clears the journal
+func RecoverChange(entry *JournalEntry) error {
+	if err := RevertToSnapshot(entry.Previous); err != nil {
+		return err
+	}
+	return EndChange()
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package display
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"syscall"
+)
+
+// processAlive reports whether a process with the pid is running
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// processStart identifies the running process with the pid across pid reuse:
+// the boot ID and its start time in clock ticks since that boot, from /proc
+func processStart(pid int) (string, error) {
+	bootID, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", fmt.Errorf("could not read the boot ID: %v", err)
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", fmt.Errorf("could not read the process status: %v", err)
+	}
+	// The command name in parentheses may hold spaces, the fields after it
+	// start with the state (field 3) and the start time is field 22
+	end := strings.LastIndexByte(string(stat), ')')
+	fields := strings.Fields(string(stat[end+1:]))
+	if end < 0 || len(fields) < 20 {
+		return "", fmt.Errorf("invalid process status of %d", pid)
+	}
+	return strings.TrimSpace(string(bootID)) + "/" + fields[19], nil
+}
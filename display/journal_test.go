@@ -0,0 +1,87 @@
+package display
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestUnfinishedChange(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep command to stand in for a running WRM")
+	}
+	// Another WRM waiting for its change to be kept
+	other := exec.Command(sleep, "30")
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		other.Process.Kill()
+		other.Wait()
+	})
+	start, err := processStart(other.Process.Pid)
+	if err != nil {
+		t.Skipf("the start of a process cannot be read here: %v", err)
+	}
+	// A process that has exited
+	exited := exec.Command(sleep, "0")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		pid            int
+		start          string
+		wantUnfinished bool
+	}{
+		{name: "running", pid: other.Process.Pid, start: start},
+		// After a crash and reboot the PID belongs to an unrelated process
+		{name: "pid reused", pid: other.Process.Pid, start: "another boot/1234", wantUnfinished: true},
+		{name: "no start recorded", pid: other.Process.Pid, wantUnfinished: true},
+		{name: "exited", pid: exited.Process.Pid, start: start, wantUnfinished: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStateDir(t.TempDir())
+			t.Cleanup(func() { SetStateDir("") })
+			data, err := json.Marshal(JournalEntry{PID: tt.pid, Start: tt.start, Time: time.Now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(statePath(JOURNAL_FILE), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			entry, err := UnfinishedChange()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (entry != nil) != tt.wantUnfinished {
+				t.Errorf("unfinished = %v, want %v", entry != nil, tt.wantUnfinished)
+			}
+		})
+	}
+}
+
+func TestBeginChangeRecordsStart(t *testing.T) {
+	SetStateDir(t.TempDir())
+	t.Cleanup(func() { SetStateDir("") })
+	if err := BeginChange(Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(statePath(JOURNAL_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if want, err := processStart(entry.PID); err == nil && entry.Start != want {
+		t.Errorf("start = %q, want %q", entry.Start, want)
+	}
+}
@@ -0,0 +1,40 @@
+package display
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const (
+	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+	STILL_ACTIVE                      = 259
+)
+
+// processAlive reports whether a process with the pid is running
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == STILL_ACTIVE
+}
+
+// processStart identifies the running process with the pid across pid reuse
+// by its creation time, which also differs after a reboot
+func processStart(pid int) (string, error) {
+	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return "", fmt.Errorf("could not open process %d: %v", pid, err)
+	}
+	defer syscall.CloseHandle(handle)
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return "", fmt.Errorf("could not read the times of process %d: %v", pid, err)
+	}
+	return fmt.Sprintf("%d", creation.Nanoseconds()), nil
+}
@@ -0,0 +1,324 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+)
+
+// KScreenBackend drives KDE Plasma sessions through kscreen-doctor
+type KScreenBackend struct {
+	Command string // Path to the kscreen-doctor binary
+	DRMRoot string // Directory of the DRM connectors the EDIDs are read from, kscreen-doctor does not print them
+}
+
+// kscreenConfig is the document printed by `kscreen-doctor -j`
+type kscreenConfig struct {
+	Outputs []kscreenOutput `json:"outputs"`
+}
+
+// kscreenOutput is a single output of the kscreen configuration
+type kscreenOutput struct {
+	ID            int           `json:"id"`
+	Name          string        `json:"name"`
+	Connected     bool          `json:"connected"`
+	Enabled       bool          `json:"enabled"`
+	CurrentModeID string        `json:"currentModeId"`
+	Modes         []kscreenMode `json:"modes"`
+	Pos           struct {
+		X int32 `json:"x"`
+		Y int32 `json:"y"`
+	} `json:"pos"`
+	Rotation int     `json:"rotation"`
+	Scale    float64 `json:"scale"`
+}
+
+// kscreenMode is a mode of an output, e.g. {"id": "3", "name": "1920x1080@60", ...}
+type kscreenMode struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	RefreshRate float64 `json:"refreshRate"`
+	Size        struct {
+		Width  uint32 `json:"width"`
+		Height uint32 `json:"height"`
+	} `json:"size"`
+}
+
+// kscreenRotations maps orientations to the rotation names of kscreen-doctor
+// and the values of the "rotation" field in its JSON
+var kscreenRotations = map[Orientation]struct {
+	Name  string
+	Value int
+}{
+	OrientationLandscape:        {"none", 1},
+	OrientationPortrait:         {"left", 2},
+	OrientationLandscapeFlipped: {"inverted", 4},
+	OrientationPortraitFlipped:  {"right", 8},
+}
+
+// NewKScreenBackend returns a backend using the kscreen-doctor found in PATH
+func NewKScreenBackend() *KScreenBackend {
+	return &KScreenBackend{Command: "kscreen-doctor", DRMRoot: "/sys/class/drm"}
+}
+
+func (b *KScreenBackend) Name() string {
+	return "kscreen"
+}
+
+// parseKScreenJSON parses the output of `kscreen-doctor -j`
+func parseKScreenJSON(data []byte) (*kscreenConfig, error) {
+	var config kscreenConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid kscreen-doctor JSON: %v", err)
+	}
+	return &config, nil
+}
+
+// query runs kscreen-doctor and returns the parsed configuration
+func (b *KScreenBackend) query() (*kscreenConfig, error) {
+	data, err := runCommand(b.Command, "-j")
+	if err != nil {
+		return nil, err
+	}
+	return parseKScreenJSON(data)
+}
+
+// findOutput returns the output with the given name
+func (b *KScreenBackend) findOutput(name string) (*kscreenOutput, error) {
+	config, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	for i := range config.Outputs {
+		if config.Outputs[i].Name == name {
+			return &config.Outputs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("output %s not found", name)
+}
+
+// toMode converts a kscreen mode into a backend-neutral Mode
+func (m kscreenMode) toMode() Mode {
+	return Mode{
+		Width:     m.Size.Width,
+		Height:    m.Size.Height,
+		Frequency: uint32(math.Round(m.RefreshRate)),
+		Rate:      rateFromHz(m.RefreshRate),
+	}
+}
+
+// findMode picks the kscreen mode closest to the requested one
+func (o *kscreenOutput) findMode(mode Mode) (*kscreenMode, error) {
+	var best *kscreenMode
+	for i := range o.Modes {
+		m := &o.Modes[i]
+		if m.toMode() != mode {
+			continue
+		}
+		if best == nil || math.Abs(m.RefreshRate-float64(mode.Frequency)) < math.Abs(best.RefreshRate-float64(mode.Frequency)) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("mode %s not available on %s", mode, o.Name)
+	}
+	return best, nil
+}
+
+// monitorInfo converts the output into a MonitorInfo, its ID is built from the
+// EDID like on the other backends when the DRM connector can be found
+func (b *KScreenBackend) monitorInfo(o kscreenOutput) MonitorInfo {
+	raw, _ := b.ReadEDID(o.Name)
+	mi := MonitorInfo{
+		Id:           uint32(o.ID),
+		FriendlyName: o.Name,
+		DeviceName:   o.Name,
+		MonitorID:    edidMonitorID(raw, o.Name),
+	}
+	if !o.Enabled {
+		mi.Status = MONITOR_STATUS_DISABLED
+	}
+	return mi
+}
+
+// ReadEDID reads the EDID of the DRM connector the output is named after, e.g. card0-DP-1 for DP-1
+func (b *KScreenBackend) ReadEDID(deviceName string) ([]byte, error) {
+	if b.DRMRoot == "" {
+		return nil, fmt.Errorf("the kscreen backend has no DRM directory to read EDID from")
+	}
+	matches, err := filepath.Glob(filepath.Join(b.DRMRoot, "card*-"+deviceName))
+	if err != nil || len(matches) == 0 {
+		return nil, fmt.Errorf("no DRM connector found for %s", deviceName)
+	}
+	drm := &SysfsBackend{Root: b.DRMRoot}
+	return drm.ReadEDID(filepath.Base(matches[0]))
+}
+
+// ListMonitors returns every connected and enabled output
+func (b *KScreenBackend) ListMonitors() ([]MonitorInfo, error) {
+	config, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors []MonitorInfo
+	for _, output := range config.Outputs {
+		if output.Connected && output.Enabled {
+			monitors = append(monitors, b.monitorInfo(output))
+		}
+	}
+	return monitors, nil
+}
+
+// ListAllMonitors returns the enabled outputs followed by the connected ones that are disabled
+func (b *KScreenBackend) ListAllMonitors() ([]MonitorInfo, error) {
+	config, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors, disabled []MonitorInfo
+	for _, output := range config.Outputs {
+		if !output.Connected {
+			continue
+		}
+		if output.Enabled {
+			monitors = append(monitors, b.monitorInfo(output))
+		} else {
+			disabled = append(disabled, b.monitorInfo(output))
+		}
+	}
+	return append(monitors, disabled...), nil
+}
+
+// SetEnabled switches outputs on or off in a single kscreen-doctor invocation
+func (b *KScreenBackend) SetEnabled(states map[string]bool) error {
+	config, err := b.query()
+	if err != nil {
+		return err
+	}
+	var args []string
+	for _, output := range config.Outputs {
+		on, ok := states[b.monitorInfo(output).MonitorID]
+		if !ok || !output.Connected {
+			continue
+		}
+		if on {
+			args = append(args, fmt.Sprintf("output.%d.enable", output.ID))
+		} else {
+			args = append(args, fmt.Sprintf("output.%d.disable", output.ID))
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("none of the monitors to switch is connected")
+	}
+	_, err = runCommand(b.Command, args...)
+	return err
+}
+
+func (b *KScreenBackend) ListModes(deviceName string) ([]Mode, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	modes := make([]Mode, 0, len(output.Modes))
+	for _, m := range output.Modes {
+		modes = append(modes, m.toMode())
+	}
+	return modes, nil
+}
+
+func (b *KScreenBackend) CurrentMode(deviceName string) (Mode, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return Mode{}, err
+	}
+	for _, m := range output.Modes {
+		if m.ID == output.CurrentModeID {
+			return m.toMode(), nil
+		}
+	}
+	return Mode{}, fmt.Errorf("output %s has no active mode", deviceName)
+}
+
+// CurrentPosition returns the position of the output in the Plasma layout
+func (b *KScreenBackend) CurrentPosition(deviceName string) (Position, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{X: output.Pos.X, Y: output.Pos.Y}, nil
+}
+
+// LayoutScale returns the scale of the output, Plasma lays the desktop out in
+// logical pixels and keeps the scale when the mode changes
+func (b *KScreenBackend) LayoutScale(deviceName string, mode Mode) (float64, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return 0, err
+	}
+	return output.Scale, nil
+}
+
+// CurrentOrientation returns the rotation of the output
+func (b *KScreenBackend) CurrentOrientation(deviceName string) (Orientation, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return OrientationLandscape, err
+	}
+	for o, rotation := range kscreenRotations {
+		if rotation.Value == output.Rotation {
+			return o, nil
+		}
+	}
+	return OrientationLandscape, nil
+}
+
+// modeArg returns the kscreen-doctor setting switching the output to the mode, e.g. "output.1.mode.4".
+// The mode is passed by its ID, KDE names 59.94 and 60 Hz modes the same.
+func (b *KScreenBackend) modeArg(deviceName string, mode Mode) (string, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return "", err
+	}
+	m, err := output.findMode(mode)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("output.%d.mode.%s", output.ID, m.ID), nil
+}
+
+// TestMode checks that the output advertises the mode, kscreen-doctor has no dry run
+func (b *KScreenBackend) TestMode(change ModeChange) error {
+	_, err := b.modeArg(change.DeviceName, change.Mode)
+	return err
+}
+
+// ApplyModes changes every output with a single kscreen-doctor invocation
+func (b *KScreenBackend) ApplyModes(changes []ModeChange) error {
+	var args []string
+	for _, c := range changes {
+		arg, err := b.modeArg(c.DeviceName, c.Mode)
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+		if c.Orientation == nil && c.Position == nil && !c.Primary {
+			continue
+		}
+		output, err := b.findOutput(c.DeviceName)
+		if err != nil {
+			return err
+		}
+		if c.Orientation != nil {
+			args = append(args, fmt.Sprintf("output.%d.rotation.%s", output.ID, kscreenRotations[*c.Orientation].Name))
+		}
+		if c.Position != nil {
+			args = append(args, fmt.Sprintf("output.%d.position.%d,%d", output.ID, c.Position.X, c.Position.Y))
+		}
+		if c.Primary {
+			args = append(args, fmt.Sprintf("output.%d.primary", output.ID))
+		}
+	}
+	_, err := runCommand(b.Command, args...)
+	return err
+}
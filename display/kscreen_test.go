@@ -0,0 +1,224 @@
+package display
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestKScreenMonitorInfo(t *testing.T) {
+	root := t.TempDir()
+	writeTestConnector(t, root, "card1-DP-1", map[string][]byte{"edid": makeTestEDID("AOC", 0x2702, "27G2G5")})
+	writeTestConnector(t, root, "card1-eDP-1", map[string][]byte{"edid": {}})
+	tests := []struct {
+		name    string
+		drmRoot string
+		output  kscreenOutput
+		want    MonitorInfo
+	}{
+		{
+			name:    "ID from the EDID of the DRM connector",
+			drmRoot: root,
+			output:  kscreenOutput{ID: 1, Name: "DP-1", Connected: true, Enabled: true},
+			want:    MonitorInfo{Id: 1, FriendlyName: "DP-1", DeviceName: "DP-1", MonitorID: "AOC-2702-DP-1"},
+		},
+		{
+			name:    "connector without EDID",
+			drmRoot: root,
+			output:  kscreenOutput{ID: 2, Name: "eDP-1", Connected: true},
+			want:    MonitorInfo{Id: 2, FriendlyName: "eDP-1", DeviceName: "eDP-1", MonitorID: "eDP-1", Status: MONITOR_STATUS_DISABLED},
+		},
+		{
+			name:   "no DRM directory",
+			output: kscreenOutput{ID: 1, Name: "DP-1", Connected: true, Enabled: true},
+			want:   MonitorInfo{Id: 1, FriendlyName: "DP-1", DeviceName: "DP-1", MonitorID: "DP-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &KScreenBackend{Command: "kscreen-doctor", DRMRoot: tt.drmRoot}
+			if got := b.monitorInfo(tt.output); got != tt.want {
+				t.Errorf("monitorInfo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKScreenJSON(t *testing.T) {
+	// A scaled laptop panel below a monitor rotated to portrait on the right, a
+	// connected monitor that is switched off and an empty connector
+	config, err := parseKScreenJSON(readTestdata(t, "kscreen_doctor.json"))
+	if err != nil {
+		t.Fatalf("parseKScreenJSON failed: %v", err)
+	}
+	tests := []struct {
+		name        string
+		id          int
+		connected   bool
+		enabled     bool
+		currentMode string
+		position    Position
+		rotation    int
+		scale       float64
+		modes       []Mode
+	}{
+		{
+			name: "eDP-1", id: 1, connected: true, enabled: true, currentMode: "1",
+			position: Position{Y: 840}, rotation: 1, scale: 1.25,
+			modes: []Mode{
+				{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 59999, Denominator: 1000}},
+				{Width: 1920, Height: 1080, Frequency: 48, Rate: RefreshRate{Numerator: 47998, Denominator: 1000}},
+			},
+		},
+		{
+			name: "DP-1", id: 2, connected: true, enabled: true, currentMode: "3",
+			position: Position{X: 1536}, rotation: 2, scale: 1,
+			modes: []Mode{
+				{Width: 1920, Height: 1080, Frequency: 144, Rate: RefreshRate{Numerator: 144004, Denominator: 1000}},
+				{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 60000, Denominator: 1000}},
+				{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 59940, Denominator: 1000}},
+			},
+		},
+		{
+			name: "HDMI-A-1", id: 3, connected: true, rotation: 1, scale: 1,
+			modes: []Mode{
+				{Width: 1280, Height: 1024, Frequency: 75, Rate: RefreshRate{Numerator: 75025, Denominator: 1000}},
+			},
+		},
+		{name: "DP-2", id: 4, rotation: 1, scale: 1},
+	}
+	if len(config.Outputs) != len(tests) {
+		t.Fatalf("got %d outputs, want %d", len(config.Outputs), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := config.Outputs[i]
+			if o.Name != tt.name || o.ID != tt.id || o.Connected != tt.connected || o.Enabled != tt.enabled {
+				t.Errorf("output = %s %d connected %v enabled %v, want %s %d connected %v enabled %v",
+					o.Name, o.ID, o.Connected, o.Enabled, tt.name, tt.id, tt.connected, tt.enabled)
+			}
+			if o.CurrentModeID != tt.currentMode {
+				t.Errorf("current mode = %q, want %q", o.CurrentModeID, tt.currentMode)
+			}
+			if position := (Position{X: o.Pos.X, Y: o.Pos.Y}); position != tt.position {
+				t.Errorf("position = %v, want %v", position, tt.position)
+			}
+			if o.Rotation != tt.rotation || o.Scale != tt.scale {
+				t.Errorf("rotation and scale = %d %v, want %d %v", o.Rotation, o.Scale, tt.rotation, tt.scale)
+			}
+			var modes []Mode
+			for _, m := range o.Modes {
+				modes = append(modes, m.toMode())
+			}
+			if !reflect.DeepEqual(modes, tt.modes) {
+				t.Errorf("modes = %v, want %v", modes, tt.modes)
+			}
+		})
+	}
+}
+
+func TestKScreenFindMode(t *testing.T) {
+	config, err := parseKScreenJSON(readTestdata(t, "kscreen_doctor.json"))
+	if err != nil {
+		t.Fatalf("parseKScreenJSON failed: %v", err)
+	}
+	dp := config.Outputs[1]
+	tests := []struct {
+		name    string
+		mode    Mode
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "exact rate",
+			mode: Mode{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 59940, Denominator: 1000}},
+			want: "5",
+		},
+		{
+			name: "whole Hz among fractional rates",
+			mode: Mode{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 60000, Denominator: 1000}},
+			want: "4",
+		},
+		{
+			name:    "mode of another output",
+			mode:    Mode{Width: 1280, Height: 1024, Frequency: 75, Rate: RefreshRate{Numerator: 75025, Denominator: 1000}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := dp.findMode(tt.mode)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("findMode found mode %s", m.ID)
+				}
+				return
+			}
+			if err != nil || m.ID != tt.want {
+				t.Errorf("findMode = %v, %v, want mode %s", m, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKScreenJSONInvalid(t *testing.T) {
+	if _, err := parseKScreenJSON([]byte(`{"outputs": {}}`)); err == nil {
+		t.Errorf("parseKScreenJSON accepted outputs that are not a list")
+	}
+}
+
+func TestKScreenApplyModes(t *testing.T) {
+	mode := func(hz float64) Mode {
+		return Mode{Width: 1920, Height: 1080, Frequency: uint32(math.Round(hz)), Rate: rateFromHz(hz)}
+	}
+	portrait := OrientationPortrait
+	tests := []struct {
+		name    string
+		change  ModeChange
+		want    string
+		wantErr bool
+	}{
+		// Both modes are named 1920x1080@60, only the ID tells them apart
+		{name: "60 Hz", change: ModeChange{DeviceName: "DP-1", Mode: mode(60)}, want: "output.2.mode.4"},
+		{name: "59.94 Hz", change: ModeChange{DeviceName: "DP-1", Mode: mode(59.94)}, want: "output.2.mode.5"},
+		{
+			name:   "rotated, moved and primary",
+			change: ModeChange{DeviceName: "DP-1", Mode: mode(144.004), Orientation: &portrait, Position: &Position{X: 1536}, Primary: true},
+			want:   "output.2.mode.3 output.2.rotation.left output.2.position.1536,0 output.2.primary",
+		},
+		{name: "unknown mode", change: ModeChange{DeviceName: "DP-1", Mode: mode(75)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, log := fakeTool(t, "kscreen_doctor.json")
+			b := &KScreenBackend{Command: command}
+			err := b.ApplyModes([]ModeChange{tt.change})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ApplyModes accepted %v", tt.change.Mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyModes failed: %v", err)
+			}
+			if got := lastInvocation(t, log); got != tt.want {
+				t.Errorf("kscreen-doctor %s, want kscreen-doctor %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKScreenResolveLayout(t *testing.T) {
+	command, _ := fakeTool(t, "kscreen_doctor.json")
+	useBackend(t, &KScreenBackend{Command: command})
+
+	// The panel runs 1920x1080 at scale 1.25, it covers 1536x864 of the desktop
+	changes := []ModeChange{{DeviceName: "DP-1", Mode: Mode{Width: 1920, Height: 1080, Frequency: 60, Rate: rateFromHz(60)}}}
+	if err := ResolveLayout(changes, map[string]Placement{"DP-1": {RightOf: "eDP-1"}}); err != nil {
+		t.Fatalf("ResolveLayout failed: %v", err)
+	}
+	if want := (Position{X: 1536, Y: 840}); changes[0].Position == nil || *changes[0].Position != want {
+		t.Errorf("position = %v, want %v", changes[0].Position, want)
+	}
+}
@@ -0,0 +1,392 @@
+package display
+
+import (
+	"fmt"
+	"math"
+)
+
+// Position is the origin of a monitor on the virtual desktop
+type Position struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+}
+
+// PositionReader is implemented by backends that can report where a monitor sits on the desktop
+type PositionReader interface {
+	CurrentPosition(deviceName string) (Position, error)
+}
+
+// CurrentPosition returns the desktop origin of the device, if the active backend can report it
+func CurrentPosition(deviceName string) (Position, error) {
+	reader, ok := CurrentBackend().(PositionReader)
+	if !ok {
+		return Position{}, fmt.Errorf("the %s backend cannot report monitor positions", CurrentBackend().Name())
+	}
+	return reader.CurrentPosition(deviceName)
+}
+
+// LayoutScaler is implemented by backends that lay the desktop out in logical
+// pixels, where a monitor covers its mode divided by its scale
+type LayoutScaler interface {
+	LayoutScale(deviceName string, mode Mode) (float64, error)
+}
+
+// layoutScale returns the scale the device would have in the mode, 1 on
+// backends that lay the desktop out in physical pixels
+func layoutScale(deviceName string, mode Mode) (float64, error) {
+	scaler, ok := CurrentBackend().(LayoutScaler)
+	if !ok {
+		return 1, nil
+	}
+	scale, err := scaler.LayoutScale(deviceName, mode)
+	if err != nil {
+		return 0, err
+	}
+	if scale <= 0 {
+		return 1, nil
+	}
+	return scale, nil
+}
+
+// Placement describes where a monitor goes on the desktop, either at an absolute
+// position or next to another monitor given by its device name. Monitors placed
+// left or right of another share its top edge, monitors placed above or below
+// share its left edge.
+type Placement struct {
+	Position *Position
+	RightOf  string
+	LeftOf   string
+	Above    string
+	Below    string
+}
+
+// reference returns the device the placement is relative to, or "" for absolute placements
+func (p Placement) reference() string {
+	switch {
+	case p.RightOf != "":
+		return p.RightOf
+	case p.LeftOf != "":
+		return p.LeftOf
+	case p.Above != "":
+		return p.Above
+	}
+	return p.Below
+}
+
+// layoutRect is a monitor on the desktop while a layout is resolved
+type layoutRect struct {
+	DeviceName string
+	Width      int32
+	Height     int32
+	Position   *Position // nil until the position is known
+}
+
+// newLayoutRect returns the area a monitor covers on the desktop, rotated monitors
+// swap their sides and scaled monitors shrink on backends with a logical layout
+func newLayoutRect(deviceName string, mode Mode, orientation Orientation) (*layoutRect, error) {
+	scale, err := layoutScale(deviceName, mode)
+	if err != nil {
+		return nil, err
+	}
+	rect := &layoutRect{
+		DeviceName: deviceName,
+		Width:      int32(math.Round(float64(mode.Width) / scale)),
+		Height:     int32(math.Round(float64(mode.Height) / scale)),
+	}
+	if orientation.SwapsAxes() {
+		rect.Width, rect.Height = rect.Height, rect.Width
+	}
+	return rect, nil
+}
+
+// overlaps reports whether the two rectangles share any area
+func (r *layoutRect) overlaps(o *layoutRect) bool {
+	return r.Position.X < o.Position.X+o.Width && o.Position.X < r.Position.X+r.Width &&
+		r.Position.Y < o.Position.Y+o.Height && o.Position.Y < r.Position.Y+r.Height
+}
+
+// touches reports whether the two rectangles share a piece of an edge
+func (r *layoutRect) touches(o *layoutRect) bool {
+	overlapX := r.Position.X < o.Position.X+o.Width && o.Position.X < r.Position.X+r.Width
+	overlapY := r.Position.Y < o.Position.Y+o.Height && o.Position.Y < r.Position.Y+r.Height
+	adjacentX := r.Position.X+r.Width == o.Position.X || o.Position.X+o.Width == r.Position.X
+	adjacentY := r.Position.Y+r.Height == o.Position.Y || o.Position.Y+o.Height == r.Position.Y
+	return (adjacentX && overlapY) || (adjacentY && overlapX)
+}
+
+// ResolveLayout computes the absolute position of every change that has a
+// placement, keyed by device name, and checks that the resulting desktop has
+// no overlapping monitors and no gaps. Monitors without a placement keep their
+// current position.
+func ResolveLayout(changes []ModeChange, placements map[string]Placement) error {
+	if len(placements) == 0 {
+		return nil
+	}
+	monitors, err := ListMonitors()
+	if err != nil {
+		return err
+	}
+
+	// Start from the current desktop, with the new sizes of the changed monitors
+	rects := make(map[string]*layoutRect)
+	var order []string
+	for _, mi := range monitors {
+		mode, err := CurrentBackend().CurrentMode(mi.DeviceName)
+		if err != nil {
+			return err
+		}
+		orientation, err := CurrentOrientation(mi.DeviceName)
+		if err != nil {
+			return err
+		}
+		if rects[mi.DeviceName], err = newLayoutRect(mi.DeviceName, mode, orientation); err != nil {
+			return err
+		}
+		order = append(order, mi.DeviceName)
+	}
+	for _, c := range changes {
+		current, ok := rects[c.DeviceName]
+		if !ok {
+			return fmt.Errorf("monitor %s is not active", c.DeviceName)
+		}
+		orientation, err := CurrentOrientation(c.DeviceName)
+		if err != nil {
+			return err
+		}
+		if c.Orientation != nil {
+			orientation = *c.Orientation
+		}
+		rect, err := newLayoutRect(c.DeviceName, c.Mode, orientation)
+		if err != nil {
+			return err
+		}
+		*current = *rect
+	}
+	for deviceName := range placements {
+		if _, ok := rects[deviceName]; !ok {
+			return fmt.Errorf("monitor %s is not active", deviceName)
+		}
+	}
+	for _, deviceName := range order {
+		if _, ok := placements[deviceName]; ok {
+			continue
+		}
+		pos, err := CurrentPosition(deviceName)
+		if err != nil {
+			return err
+		}
+		rects[deviceName].Position = &pos
+	}
+
+	// Resolve relative placements until nothing moves anymore, whatever is
+	// left refers to a missing monitor or to itself through a cycle
+	for resolved := true; resolved; {
+		resolved = false
+		for deviceName, p := range placements {
+			rect := rects[deviceName]
+			if rect.Position != nil {
+				continue
+			}
+			if p.Position != nil {
+				pos := *p.Position
+				rect.Position = &pos
+				resolved = true
+				continue
+			}
+			ref, ok := rects[p.reference()]
+			if !ok {
+				return fmt.Errorf("monitor %s is placed next to %s which is not active", deviceName, p.reference())
+			}
+			if ref.Position == nil {
+				continue
+			}
+			pos := *ref.Position
+			switch {
+			case p.RightOf != "":
+				pos.X += ref.Width
+			case p.LeftOf != "":
+				pos.X -= rect.Width
+			case p.Above != "":
+				pos.Y -= rect.Height
+			default:
+				pos.Y += ref.Height
+			}
+			rect.Position = &pos
+			resolved = true
+		}
+	}
+	for deviceName := range placements {
+		if rects[deviceName].Position == nil {
+			return fmt.Errorf("cannot place monitor %s, its placement is circular", deviceName)
+		}
+	}
+
+	if err := checkLayout(rects, order); err != nil {
+		return err
+	}
+	for i := range changes {
+		if _, ok := placements[changes[i].DeviceName]; ok {
+			changes[i].Position = rects[changes[i].DeviceName].Position
+		}
+	}
+	return nil
+}
+
+// OriginPrimary is implemented by backends whose primary monitor is the one at
+// 0,0, the rest of the desktop may reach into negative coordinates around it.
+// On the other backends the desktop starts at 0,0, X11 rejects negative positions.
+type OriginPrimary interface {
+	PrimaryAtOrigin()
+}
+
+// NormalizeLayout shifts the desktop so that it starts at 0,0 on backends
+// without an OriginPrimary. Monitors that are not part of the changes are added
+// with their current mode when they have to move along.
+func NormalizeLayout(changes []ModeChange) ([]ModeChange, error) {
+	if _, ok := CurrentBackend().(OriginPrimary); ok {
+		return changes, nil
+	}
+	moved := false
+	for _, c := range changes {
+		moved = moved || c.Position != nil
+	}
+	if !moved {
+		return changes, nil
+	}
+	monitors, err := ListMonitors()
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the top left corner of the new desktop
+	positions := make(map[string]Position)
+	for _, mi := range monitors {
+		pos, err := CurrentPosition(mi.DeviceName)
+		if err != nil {
+			return nil, err
+		}
+		positions[mi.DeviceName] = pos
+	}
+	for _, c := range changes {
+		if c.Position != nil {
+			positions[c.DeviceName] = *c.Position
+		}
+	}
+	var offset Position
+	first := true
+	for _, pos := range positions {
+		if first || pos.X < offset.X {
+			offset.X = pos.X
+		}
+		if first || pos.Y < offset.Y {
+			offset.Y = pos.Y
+		}
+		first = false
+	}
+	if offset.X == 0 && offset.Y == 0 {
+		return changes, nil
+	}
+
+	// Every monitor moves by the same offset so the layout is kept
+	for _, mi := range monitors {
+		found := false
+		for _, c := range changes {
+			found = found || c.DeviceName == mi.DeviceName
+		}
+		if found {
+			continue
+		}
+		mode, err := CurrentBackend().CurrentMode(mi.DeviceName)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ModeChange{DeviceName: mi.DeviceName, Mode: mode})
+	}
+	for i := range changes {
+		pos := positions[changes[i].DeviceName]
+		changes[i].Position = &Position{X: pos.X - offset.X, Y: pos.Y - offset.Y}
+	}
+	return changes, nil
+}
+
+// MakePrimary marks the device as the primary monitor and shifts the desktop so
+// that it sits at 0,0. Monitors that are not part of the changes are added with
+// their current mode so they move along with the others.
+func MakePrimary(changes []ModeChange, deviceName string) ([]ModeChange, error) {
+	monitors, err := ListMonitors()
+	if err != nil {
+		return nil, err
+	}
+	for _, mi := range monitors {
+		found := false
+		for _, c := range changes {
+			if c.DeviceName == mi.DeviceName {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		mode, err := CurrentBackend().CurrentMode(mi.DeviceName)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ModeChange{DeviceName: mi.DeviceName, Mode: mode})
+	}
+
+	// Every monitor gets an explicit position, relative to the new primary
+	var origin *Position
+	for i := range changes {
+		if changes[i].Position == nil {
+			pos, err := CurrentPosition(changes[i].DeviceName)
+			if err != nil {
+				return nil, err
+			}
+			changes[i].Position = &pos
+		}
+		if changes[i].DeviceName == deviceName {
+			origin = changes[i].Position
+		}
+	}
+	if origin == nil {
+		return nil, fmt.Errorf("monitor %s is not active", deviceName)
+	}
+	offset := *origin
+	for i := range changes {
+		changes[i].Position = &Position{X: changes[i].Position.X - offset.X, Y: changes[i].Position.Y - offset.Y}
+		changes[i].Primary = changes[i].DeviceName == deviceName
+	}
+	return changes, nil
+}
+
+// checkLayout rejects desktops with overlapping monitors or monitors that do not touch the others
+func checkLayout(rects map[string]*layoutRect, order []string) error {
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			if rects[a].overlaps(rects[b]) {
+				return fmt.Errorf("monitors %s and %s overlap", a, b)
+			}
+		}
+	}
+
+	// Walk the monitors that touch each other starting from the first one
+	connected := map[string]bool{order[0]: true}
+	queue := []string{order[0]}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, other := range order {
+			if !connected[other] && rects[current].touches(rects[other]) {
+				connected[other] = true
+				queue = append(queue, other)
+			}
+		}
+	}
+	for _, deviceName := range order {
+		if !connected[deviceName] {
+			return fmt.Errorf("monitor %s leaves a gap, it does not touch the rest of the desktop", deviceName)
+		}
+	}
+	return nil
+}
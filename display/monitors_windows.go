@@ -0,0 +1,341 @@
+package display
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"syscall"
+	"unsafe"
+	"windows-resolution-manager/edid"
+)
+
+var (
+	user32                          = syscall.NewLazyDLL("user32.dll")
+	procGetDisplayConfigBufferSizes = user32.NewProc("GetDisplayConfigBufferSizes")
+	procQueryDisplayConfig          = user32.NewProc("QueryDisplayConfig")
+	procDisplayConfigGetDeviceInfo  = user32.NewProc("DisplayConfigGetDeviceInfo")
+)
+
+const (
+	QDC_ONLY_ACTIVE_PATHS                     = 0x00000002
+	DISPLAYCONFIG_DEVICE_INFO_GET_TARGET_NAME = 0x00000002
+	DISPLAYCONFIG_DEVICE_INFO_GET_SOURCE_NAME = 0x00000001
+	ERROR_SUCCESS                             = 0
+)
+
+// DISPLAYCONFIG_TARGET_DEVICE_NAME_FLAGS bits
+const (
+	DISPLAYCONFIG_TARGET_EDID_IDS_VALID = 0x00000004
+)
+
+// outputTechnologies maps DISPLAYCONFIG_VIDEO_OUTPUT_TECHNOLOGY values to short connector names
+var outputTechnologies = map[uint32]string{
+	0:          "VGA",
+	1:          "SVIDEO",
+	2:          "COMPOSITE",
+	3:          "COMPONENT",
+	4:          "DVI",
+	5:          "HDMI",
+	6:          "LVDS",
+	8:          "DJPN",
+	9:          "SDI",
+	10:         "DP",
+	11:         "eDP",
+	12:         "UDI",
+	13:         "eUDI",
+	14:         "SDTV",
+	15:         "MIRACAST",
+	16:         "INDIRECT",
+	17:         "VIRTUAL",
+	0x80000000: "INTERNAL",
+}
+
+// win32MonitorID builds the stable ID of a target, e.g. "AOC-2702-DP-1". The
+// EDID ids come from the monitor itself and the connector instance from the
+// port it is plugged into, so the ID survives path reordering and tells
+// identical monitors apart.
+func win32MonitorID(targetName *DISPLAYCONFIG_TARGET_DEVICE_NAME) string {
+	connector, ok := outputTechnologies[targetName.OutputTechnology]
+	if !ok {
+		connector = "OTHER"
+	}
+	connector = fmt.Sprintf("%s-%d", connector, targetName.ConnectorInstance)
+	if targetName.Flags.Value&DISPLAYCONFIG_TARGET_EDID_IDS_VALID == 0 {
+		// Without EDID ids fall back to a hash of the monitor device path
+		devicePath := syscall.UTF16ToString(targetName.MonitorDevicePath[:])
+		return makeMonitorID(fmt.Sprintf("%08X", crc32.ChecksumIEEE([]byte(devicePath))), connector)
+	}
+	// The manufacturer id is stored big-endian like in the EDID
+	manufacturer := edid.DecodePNPID(targetName.EdidManufactureId>>8 | targetName.EdidManufactureId<<8)
+	return makeMonitorID(manufacturer, fmt.Sprintf("%04X", targetName.EdidProductCodeId), connector)
+}
+
+type DISPLAYCONFIG_PATH_INFO struct {
+	SourceInfo DISPLAYCONFIG_PATH_SOURCE_INFO
+	TargetInfo DISPLAYCONFIG_PATH_TARGET_INFO
+	Flags      uint32
+}
+
+type DISPLAYCONFIG_PATH_SOURCE_INFO struct {
+	AdapterId   LUID
+	Id          uint32
+	ModeInfoIdx uint32
+	StatusFlags uint32
+}
+
+type DISPLAYCONFIG_PATH_TARGET_INFO struct {
+	AdapterId        LUID
+	Id               uint32
+	ModeInfoIdx      uint32
+	OutputTechnology uint32
+	Rotation         uint32
+	Scaling          uint32
+	RefreshRate      DISPLAYCONFIG_RATIONAL
+	ScanLineOrdering uint32
+	TargetAvailable  uint32
+	StatusFlags      uint32
+}
+
+type DISPLAYCONFIG_RATIONAL struct {
+	Numerator   uint32
+	Denominator uint32
+}
+
+// DISPLAYCONFIG_MODE_INFO_TYPE values, telling which member of the union is set
+const (
+	DISPLAYCONFIG_MODE_INFO_TYPE_SOURCE        = 1
+	DISPLAYCONFIG_MODE_INFO_TYPE_TARGET        = 2
+	DISPLAYCONFIG_MODE_INFO_TYPE_DESKTOP_IMAGE = 3
+)
+
+type DISPLAYCONFIG_MODE_INFO struct {
+	InfoType  uint32
+	Id        uint32
+	AdapterId LUID
+	// The Union field is represented as a byte array, its largest member is the 48 byte target mode
+	Union [48]byte
+}
+
+type DISPLAYCONFIG_2DREGION struct {
+	Cx uint32
+	Cy uint32
+}
+
+type RECTL struct {
+	Left   int32
+	Top    int32
+	Right  int32
+	Bottom int32
+}
+
+type DISPLAYCONFIG_VIDEO_SIGNAL_INFO struct {
+	PixelRate        uint64
+	HSyncFreq        DISPLAYCONFIG_RATIONAL
+	VSyncFreq        DISPLAYCONFIG_RATIONAL
+	ActiveSize       DISPLAYCONFIG_2DREGION
+	TotalSize        DISPLAYCONFIG_2DREGION
+	VideoStandard    uint32 // Shares its bits with AdditionalSignalInfo
+	ScanLineOrdering uint32
+}
+
+type DISPLAYCONFIG_TARGET_MODE struct {
+	TargetVideoSignalInfo DISPLAYCONFIG_VIDEO_SIGNAL_INFO
+}
+
+type DISPLAYCONFIG_SOURCE_MODE struct {
+	Width       uint32
+	Height      uint32
+	PixelFormat uint32
+	Position    POINTL
+}
+
+type DISPLAYCONFIG_DESKTOP_IMAGE_INFO struct {
+	PathSourceSize     POINTL
+	DesktopImageRegion RECTL
+	DesktopImageClip   RECTL
+}
+
+// TargetMode decodes the union of a DISPLAYCONFIG_MODE_INFO_TYPE_TARGET mode
+func (m *DISPLAYCONFIG_MODE_INFO) TargetMode() (DISPLAYCONFIG_TARGET_MODE, error) {
+	var target DISPLAYCONFIG_TARGET_MODE
+	return target, m.decodeUnion(DISPLAYCONFIG_MODE_INFO_TYPE_TARGET, &target)
+}
+
+// SourceMode decodes the union of a DISPLAYCONFIG_MODE_INFO_TYPE_SOURCE mode
+func (m *DISPLAYCONFIG_MODE_INFO) SourceMode() (DISPLAYCONFIG_SOURCE_MODE, error) {
+	var source DISPLAYCONFIG_SOURCE_MODE
+	return source, m.decodeUnion(DISPLAYCONFIG_MODE_INFO_TYPE_SOURCE, &source)
+}
+
+// DesktopImageInfo decodes the union of a DISPLAYCONFIG_MODE_INFO_TYPE_DESKTOP_IMAGE mode
+func (m *DISPLAYCONFIG_MODE_INFO) DesktopImageInfo() (DISPLAYCONFIG_DESKTOP_IMAGE_INFO, error) {
+	var image DISPLAYCONFIG_DESKTOP_IMAGE_INFO
+	return image, m.decodeUnion(DISPLAYCONFIG_MODE_INFO_TYPE_DESKTOP_IMAGE, &image)
+}
+
+// decodeUnion reads the union into out after checking that it holds the expected type
+func (m *DISPLAYCONFIG_MODE_INFO) decodeUnion(infoType uint32, out interface{}) error {
+	if m.InfoType != infoType {
+		return fmt.Errorf("mode info has type %d, expected %d", m.InfoType, infoType)
+	}
+	return binary.Read(bytes.NewReader(m.Union[:]), binary.LittleEndian, out)
+}
+
+type DISPLAYCONFIG_DEVICE_INFO_HEADER struct {
+	Type      uint32
+	Size      uint32
+	AdapterId LUID
+	Id        uint32
+}
+
+type DISPLAYCONFIG_TARGET_DEVICE_NAME_FLAGS struct {
+	Value uint32
+}
+
+type DISPLAYCONFIG_TARGET_DEVICE_NAME struct {
+	Header                    DISPLAYCONFIG_DEVICE_INFO_HEADER
+	Flags                     DISPLAYCONFIG_TARGET_DEVICE_NAME_FLAGS
+	OutputTechnology          uint32
+	EdidManufactureId         uint16
+	EdidProductCodeId         uint16
+	ConnectorInstance         uint32
+	MonitorFriendlyDeviceName [64]uint16
+	MonitorDevicePath         [128]uint16
+}
+
+type DISPLAYCONFIG_SOURCE_DEVICE_NAME struct {
+	Header            DISPLAYCONFIG_DEVICE_INFO_HEADER
+	ViewGdiDeviceName [32]uint16
+}
+
+func GetDisplayConfigBufferSizes(flags uint32, numPathArrayElements *uint32, numModeInfoArrayElements *uint32) int32 {
+	ret, _, _ := procGetDisplayConfigBufferSizes.Call(
+		uintptr(flags),
+		uintptr(unsafe.Pointer(numPathArrayElements)),
+		uintptr(unsafe.Pointer(numModeInfoArrayElements)),
+	)
+	return int32(ret)
+}
+
+func QueryDisplayConfig(flags uint32, numPathArrayElements *uint32, pathArray *DISPLAYCONFIG_PATH_INFO, numModeInfoArrayElements *uint32, modeInfoArray *DISPLAYCONFIG_MODE_INFO, currentTopologyId *uint32) int32 {
+	ret, _, _ := procQueryDisplayConfig.Call(
+		uintptr(flags),
+		uintptr(unsafe.Pointer(numPathArrayElements)),
+		uintptr(unsafe.Pointer(pathArray)),
+		uintptr(unsafe.Pointer(numModeInfoArrayElements)),
+		uintptr(unsafe.Pointer(modeInfoArray)),
+		uintptr(unsafe.Pointer(currentTopologyId)),
+	)
+	return int32(ret)
+}
+
+func DisplayConfigGetDeviceInfo(requestPacket *DISPLAYCONFIG_DEVICE_INFO_HEADER) int32 {
+	ret, _, _ := procDisplayConfigGetDeviceInfo.Call(
+		uintptr(unsafe.Pointer(requestPacket)),
+	)
+	return int32(ret)
+}
+
+// GetSourceDeviceName retrieves the source device name for the monitor
+func GetSourceDeviceName(adapterId LUID, id uint32) (string, error) {
+	var deviceName DISPLAYCONFIG_SOURCE_DEVICE_NAME
+	deviceName.Header.Type = DISPLAYCONFIG_DEVICE_INFO_GET_SOURCE_NAME
+	deviceName.Header.Size = uint32(unsafe.Sizeof(deviceName))
+	deviceName.Header.AdapterId = adapterId
+	deviceName.Header.Id = id
+
+	ret := DisplayConfigGetDeviceInfo(&deviceName.Header)
+	if ret != ERROR_SUCCESS {
+		return "", fmt.Errorf("DisplayConfigGetDeviceInfo failed with error %d", ret)
+	}
+
+	return syscall.UTF16ToString(deviceName.ViewGdiDeviceName[:]), nil
+}
+
+// queryDisplayConfig returns the paths and modes selected by the QDC_* flags
+func queryDisplayConfig(flags uint32) ([]DISPLAYCONFIG_PATH_INFO, []DISPLAYCONFIG_MODE_INFO, error) {
+	var pathCount, modeCount uint32
+
+	// Get buffer sizes
+	ret := GetDisplayConfigBufferSizes(flags, &pathCount, &modeCount)
+	if ret != ERROR_SUCCESS {
+		return nil, nil, fmt.Errorf("GetDisplayConfigBufferSizes failed with error %d", ret)
+	}
+	if pathCount == 0 {
+		return nil, nil, nil
+	}
+
+	// Allocate the path and mode arrays, the spare mode keeps &modeInfoArray[0] valid without modes
+	pathArray := make([]DISPLAYCONFIG_PATH_INFO, pathCount)
+	modeInfoArray := make([]DISPLAYCONFIG_MODE_INFO, modeCount+1)
+
+	// Query display config
+	ret = QueryDisplayConfig(flags, &pathCount, &pathArray[0], &modeCount, &modeInfoArray[0], nil)
+	if ret != ERROR_SUCCESS {
+		return nil, nil, fmt.Errorf("QueryDisplayConfig failed with error %d", ret)
+	}
+	return pathArray[:pathCount], modeInfoArray[:modeCount], nil
+}
+
+// getTargetDeviceName retrieves the monitor information of a path target
+func getTargetDeviceName(adapterId LUID, id uint32) (DISPLAYCONFIG_TARGET_DEVICE_NAME, error) {
+	var targetName DISPLAYCONFIG_TARGET_DEVICE_NAME
+	targetName.Header.Type = DISPLAYCONFIG_DEVICE_INFO_GET_TARGET_NAME
+	targetName.Header.Size = uint32(unsafe.Sizeof(targetName))
+	targetName.Header.AdapterId = adapterId
+	targetName.Header.Id = id
+
+	ret := DisplayConfigGetDeviceInfo(&targetName.Header)
+	if ret != ERROR_SUCCESS {
+		return targetName, fmt.Errorf("DisplayConfigGetDeviceInfo failed with error %d", ret)
+	}
+	return targetName, nil
+}
+
+// targetFriendlyName returns the friendly name of a target
+func targetFriendlyName(targetName *DISPLAYCONFIG_TARGET_DEVICE_NAME) string {
+	friendlyName := syscall.UTF16ToString(targetName.MonitorFriendlyDeviceName[:])
+	if friendlyName == "" {
+		friendlyName = "Unknown Monitor"
+	}
+	return friendlyName
+}
+
+// ListMonitors retrieves all active monitors with their friendly names and device names
+func (win32Backend) ListMonitors() ([]MonitorInfo, error) {
+	pathArray, _, err := queryDisplayConfig(QDC_ONLY_ACTIVE_PATHS)
+	if err != nil {
+		return nil, err
+	}
+
+	var monitors []MonitorInfo
+
+	// Iterate over the paths
+	for _, path := range pathArray {
+		// Get the device info
+		targetName, err := getTargetDeviceName(path.TargetInfo.AdapterId, path.TargetInfo.Id)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		// Get the source device name
+		sourceDeviceName, err := GetSourceDeviceName(path.SourceInfo.AdapterId, path.SourceInfo.Id)
+		if err != nil {
+			fmt.Printf("GetSourceDeviceName failed with error: %v\n", err)
+			continue
+		}
+
+		monitors = append(monitors, MonitorInfo{
+			AdapterId:    path.SourceInfo.AdapterId,
+			Id:           path.SourceInfo.Id,
+			FriendlyName: targetFriendlyName(&targetName),
+			DeviceName:   sourceDeviceName,
+			DevicePath:   syscall.UTF16ToString(targetName.MonitorDevicePath[:]),
+			MonitorID:    win32MonitorID(&targetName),
+		})
+	}
+
+	return monitors, nil
+}
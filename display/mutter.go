@@ -0,0 +1,728 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	mutterBusName   = "org.gnome.Mutter.DisplayConfig"
+	mutterPath      = "/org/gnome/Mutter/DisplayConfig"
+	mutterInterface = "org.gnome.Mutter.DisplayConfig"
+
+	// Methods accepted by ApplyMonitorsConfig
+	MUTTER_METHOD_VERIFY     = 0
+	MUTTER_METHOD_TEMPORARY  = 1
+	MUTTER_METHOD_PERSISTENT = 2
+
+	// Values of the layout-mode property of GetCurrentState
+	MUTTER_LAYOUT_LOGICAL  = 1 // Logical monitors are sized in logical pixels, the mode divided by the scale
+	MUTTER_LAYOUT_PHYSICAL = 2 // Logical monitors are sized in physical pixels
+)
+
+// MutterBackend drives GNOME sessions through the org.gnome.Mutter.DisplayConfig D-Bus API
+type MutterBackend struct {
+	BusAddress string // D-Bus address to use, the session bus when empty
+	Temporary  bool   // Apply with the temporary method instead of the persistent one
+}
+
+// mutterMonitorSpec identifies a physical monitor: connector, vendor, product, serial
+type mutterMonitorSpec struct {
+	Connector string
+	Vendor    string
+	Product   string
+	Serial    string
+}
+
+// mutterMode is a mode as returned by GetCurrentState
+type mutterMode struct {
+	ID              string
+	Width           int32
+	Height          int32
+	Refresh         float64
+	PreferredScale  float64
+	SupportedScales []float64
+	Properties      map[string]dbus.Variant
+}
+
+// mutterMonitor is a physical monitor as returned by GetCurrentState
+type mutterMonitor struct {
+	Spec       mutterMonitorSpec
+	Modes      []mutterMode
+	Properties map[string]dbus.Variant
+}
+
+// mutterLogicalMonitor is a logical monitor as returned by GetCurrentState
+type mutterLogicalMonitor struct {
+	X          int32
+	Y          int32
+	Scale      float64
+	Transform  uint32
+	Primary    bool
+	Monitors   []mutterMonitorSpec
+	Properties map[string]dbus.Variant
+}
+
+// mutterState is the full reply of GetCurrentState
+type mutterState struct {
+	Serial          uint32
+	Monitors        []mutterMonitor
+	LogicalMonitors []mutterLogicalMonitor
+	Properties      map[string]dbus.Variant
+}
+
+// mutterMonitorAssignment assigns a mode to a connector in ApplyMonitorsConfig
+type mutterMonitorAssignment struct {
+	Connector  string
+	ModeID     string
+	Properties map[string]dbus.Variant
+}
+
+// mutterLogicalMonitorConfig is a logical monitor passed to ApplyMonitorsConfig
+type mutterLogicalMonitorConfig struct {
+	X         int32
+	Y         int32
+	Scale     float64
+	Transform uint32
+	Primary   bool
+	Monitors  []mutterMonitorAssignment
+}
+
+// NewMutterBackend returns a backend talking to Mutter on the session bus
+func NewMutterBackend() *MutterBackend {
+	return &MutterBackend{}
+}
+
+func (b *MutterBackend) Name() string {
+	return "mutter"
+}
+
+// connect opens a private connection to the configured bus
+func (b *MutterBackend) connect() (*dbus.Conn, error) {
+	var conn *dbus.Conn
+	var err error
+	if b.BusAddress == "" {
+		conn, err = dbus.ConnectSessionBus()
+	} else {
+		conn, err = dbus.Connect(b.BusAddress)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to D-Bus: %v", err)
+	}
+	return conn, nil
+}
+
+// getState calls GetCurrentState
+func (b *MutterBackend) getState() (*mutterState, error) {
+	conn, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var state mutterState
+	call := conn.Object(mutterBusName, mutterPath).Call(mutterInterface+".GetCurrentState", 0)
+	err = call.Store(&state.Serial, &state.Monitors, &state.LogicalMonitors, &state.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("GetCurrentState failed: %v", err)
+	}
+	return &state, nil
+}
+
+// findMonitor returns the physical monitor plugged into the connector
+func (s *mutterState) findMonitor(connector string) (*mutterMonitor, error) {
+	for i := range s.Monitors {
+		if s.Monitors[i].Spec.Connector == connector {
+			return &s.Monitors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("monitor %s not found", connector)
+}
+
+// boolProperty reads a boolean entry of a properties dictionary
+func boolProperty(props map[string]dbus.Variant, key string) bool {
+	v, ok := props[key]
+	if !ok {
+		return false
+	}
+	b, _ := v.Value().(bool)
+	return b
+}
+
+// toMode converts a Mutter mode into a backend-neutral Mode
+func (m mutterMode) toMode() Mode {
+	return Mode{
+		Width:      uint32(m.Width),
+		Height:     uint32(m.Height),
+		Frequency:  uint32(math.Round(m.Refresh)),
+		Rate:       rateFromHz(m.Refresh),
+		Interlaced: boolProperty(m.Properties, "is-interlaced"),
+	}
+}
+
+// findMode picks the Mutter mode closest to the requested one
+func (m *mutterMonitor) findMode(mode Mode) (*mutterMode, error) {
+	var best *mutterMode
+	for i := range m.Modes {
+		candidate := &m.Modes[i]
+		if candidate.toMode() != mode {
+			continue
+		}
+		if best == nil || math.Abs(candidate.Refresh-float64(mode.Frequency)) < math.Abs(best.Refresh-float64(mode.Frequency)) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("mode %s not available on %s", mode, m.Spec.Connector)
+	}
+	return best, nil
+}
+
+// currentModeID returns the id of the mode the monitor is running, or "" when it is off
+func (m *mutterMonitor) currentModeID() string {
+	for _, mode := range m.Modes {
+		if boolProperty(mode.Properties, "is-current") {
+			return mode.ID
+		}
+	}
+	return ""
+}
+
+// modeByID returns the mode with the given id, or nil when the monitor has none
+func (m *mutterMonitor) modeByID(id string) *mutterMode {
+	for i := range m.Modes {
+		if m.Modes[i].ID == id {
+			return &m.Modes[i]
+		}
+	}
+	return nil
+}
+
+// supportsScale reports whether Mutter accepts the scale for the mode
+func (m mutterMode) supportsScale(scale float64) bool {
+	for _, s := range m.SupportedScales {
+		if math.Abs(s-scale) < 1e-4 {
+			return true
+		}
+	}
+	return false
+}
+
+// fitScale keeps the scale when the mode supports it, otherwise it returns the
+// preferred scale of the mode or the supported scale closest to the old one
+func (m mutterMode) fitScale(scale float64) float64 {
+	if len(m.SupportedScales) == 0 || m.supportsScale(scale) {
+		return scale
+	}
+	if m.supportsScale(m.PreferredScale) {
+		return m.PreferredScale
+	}
+	best := m.SupportedScales[0]
+	for _, s := range m.SupportedScales[1:] {
+		if math.Abs(s-scale) < math.Abs(best-scale) {
+			best = s
+		}
+	}
+	return best
+}
+
+// preferredMode returns the mode the monitor prefers, or its first mode
+func (m *mutterMonitor) preferredMode() (*mutterMode, error) {
+	if len(m.Modes) == 0 {
+		return nil, fmt.Errorf("monitor %s has no modes", m.Spec.Connector)
+	}
+	for i := range m.Modes {
+		if boolProperty(m.Modes[i].Properties, "is-preferred") {
+			return &m.Modes[i], nil
+		}
+	}
+	return &m.Modes[0], nil
+}
+
+// monitorID returns the stable ID of the monitor
+func (m *mutterMonitor) monitorID() string {
+	return makeMonitorID(m.Spec.Vendor, m.Spec.Product, m.Spec.Connector)
+}
+
+// monitorInfo converts the monitor into a MonitorInfo
+func (m *mutterMonitor) monitorInfo(id int) MonitorInfo {
+	friendlyName := m.Spec.Product
+	if v, ok := m.Properties["display-name"]; ok {
+		if name, ok := v.Value().(string); ok && name != "" {
+			friendlyName = name
+		}
+	}
+	mi := MonitorInfo{
+		Id:           uint32(id),
+		FriendlyName: friendlyName,
+		DeviceName:   m.Spec.Connector,
+		MonitorID:    m.monitorID(),
+	}
+	if m.currentModeID() == "" {
+		mi.Status = MONITOR_STATUS_DISABLED
+	}
+	return mi
+}
+
+// ListMonitors returns every monitor that is part of a logical monitor
+func (b *MutterBackend) ListMonitors() ([]MonitorInfo, error) {
+	state, err := b.getState()
+	if err != nil {
+		return nil, err
+	}
+	var monitors []MonitorInfo
+	for i := range state.Monitors {
+		if state.Monitors[i].currentModeID() != "" {
+			monitors = append(monitors, state.Monitors[i].monitorInfo(i))
+		}
+	}
+	return monitors, nil
+}
+
+// ListAllMonitors returns the monitors in use followed by the connected ones that are off
+func (b *MutterBackend) ListAllMonitors() ([]MonitorInfo, error) {
+	state, err := b.getState()
+	if err != nil {
+		return nil, err
+	}
+	var monitors, disabled []MonitorInfo
+	for i := range state.Monitors {
+		if state.Monitors[i].currentModeID() != "" {
+			monitors = append(monitors, state.Monitors[i].monitorInfo(i))
+		} else {
+			disabled = append(disabled, state.Monitors[i].monitorInfo(i))
+		}
+	}
+	return append(monitors, disabled...), nil
+}
+
+func (b *MutterBackend) ListModes(deviceName string) ([]Mode, error) {
+	state, err := b.getState()
+	if err != nil {
+		return nil, err
+	}
+	monitor, err := state.findMonitor(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	modes := make([]Mode, 0, len(monitor.Modes))
+	for _, m := range monitor.Modes {
+		modes = append(modes, m.toMode())
+	}
+	return modes, nil
+}
+
+func (b *MutterBackend) CurrentMode(deviceName string) (Mode, error) {
+	state, err := b.getState()
+	if err != nil {
+		return Mode{}, err
+	}
+	monitor, err := state.findMonitor(deviceName)
+	if err != nil {
+		return Mode{}, err
+	}
+	for _, m := range monitor.Modes {
+		if boolProperty(m.Properties, "is-current") {
+			return m.toMode(), nil
+		}
+	}
+	return Mode{}, fmt.Errorf("monitor %s has no active mode", deviceName)
+}
+
+// CurrentPosition returns the origin of the logical monitor showing the connector
+func (b *MutterBackend) CurrentPosition(deviceName string) (Position, error) {
+	state, err := b.getState()
+	if err != nil {
+		return Position{}, err
+	}
+	for _, lm := range state.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			if spec.Connector == deviceName {
+				return Position{X: lm.X, Y: lm.Y}, nil
+			}
+		}
+	}
+	return Position{}, fmt.Errorf("monitor %s is not part of the current layout", deviceName)
+}
+
+// CurrentOrientation returns the rotation of the logical monitor showing the connector
+func (b *MutterBackend) CurrentOrientation(deviceName string) (Orientation, error) {
+	state, err := b.getState()
+	if err != nil {
+		return OrientationLandscape, err
+	}
+	for _, lm := range state.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			if spec.Connector == deviceName {
+				// Transforms 4-7 are the flipped variants of 0-3
+				return Orientation(lm.Transform % 4), nil
+			}
+		}
+	}
+	return OrientationLandscape, fmt.Errorf("monitor %s is not part of the current layout", deviceName)
+}
+
+// buildConfig rebuilds the current layout with each changed connector switched to its mode.
+// ApplyMonitorsConfig replaces the whole layout, so every logical monitor is resent.
+// A scale the new mode does not support is replaced, and unless the changes place
+// the monitors themselves, the others move along with the new sizes so that the
+// desktop keeps its shape and starts at 0,0.
+func (b *MutterBackend) buildConfig(state *mutterState, changes []ModeChange) ([]mutterLogicalMonitorConfig, error) {
+	modeIDs := make(map[string]string)
+	positions := make(map[string]*Position)
+	orientations := make(map[string]*Orientation)
+	primary := ""
+	placed := false
+	for _, c := range changes {
+		if c.Primary {
+			primary = c.DeviceName
+		}
+		placed = placed || c.Position != nil
+		positions[c.DeviceName] = c.Position
+		orientations[c.DeviceName] = c.Orientation
+		target, err := state.findMonitor(c.DeviceName)
+		if err != nil {
+			return nil, err
+		}
+		targetMode, err := target.findMode(c.Mode)
+		if err != nil {
+			return nil, err
+		}
+		modeIDs[c.DeviceName] = targetMode.ID
+	}
+
+	var configs, previous []mutterLogicalMonitorConfig
+	found := make(map[string]bool)
+	for _, lm := range state.LogicalMonitors {
+		config := mutterLogicalMonitorConfig{
+			X:         lm.X,
+			Y:         lm.Y,
+			Scale:     lm.Scale,
+			Transform: lm.Transform,
+			// A new primary takes the flag away from the current one
+			Primary: lm.Primary && primary == "",
+		}
+		current := config
+		for _, spec := range lm.Monitors {
+			monitor, err := state.findMonitor(spec.Connector)
+			if err != nil {
+				return nil, err
+			}
+			modeID := monitor.currentModeID()
+			current.Monitors = append(current.Monitors, mutterMonitorAssignment{Connector: spec.Connector, ModeID: modeID})
+			if id, ok := modeIDs[spec.Connector]; ok {
+				modeID = id
+				found[spec.Connector] = true
+			}
+			// Mirrored monitors share a logical monitor, moving one moves them all
+			if pos := positions[spec.Connector]; pos != nil {
+				config.X, config.Y = pos.X, pos.Y
+			}
+			if o := orientations[spec.Connector]; o != nil {
+				config.Transform = uint32(*o)
+			}
+			if primary != "" && spec.Connector == primary {
+				config.Primary = true
+			}
+			if mode := monitor.modeByID(modeID); mode != nil {
+				config.Scale = mode.fitScale(config.Scale)
+			}
+			config.Monitors = append(config.Monitors, mutterMonitorAssignment{
+				Connector:  spec.Connector,
+				ModeID:     modeID,
+				Properties: map[string]dbus.Variant{},
+			})
+		}
+		configs = append(configs, config)
+		previous = append(previous, current)
+	}
+	for _, c := range changes {
+		if !found[c.DeviceName] {
+			return nil, fmt.Errorf("monitor %s is not part of the current layout", c.DeviceName)
+		}
+	}
+	if placed {
+		shiftToOrigin(configs)
+	} else {
+		state.relayout(previous, configs)
+	}
+	return configs, nil
+}
+
+// relayout moves the logical monitors after their sizes changed so that the
+// ones that touched keep touching: a monitor starts at the new right edge of
+// the monitors it had on its left, and at the new bottom edge of the monitors
+// it had above it. Logical monitors without any monitor take no room.
+func (s *mutterState) relayout(previous, configs []mutterLogicalMonitorConfig) {
+	n := len(configs)
+	x, y := make([]int32, n), make([]int32, n)
+	width, height := make([]int32, n), make([]int32, n)
+	newWidth, newHeight := make([]int32, n), make([]int32, n)
+	for i := range configs {
+		x[i], y[i] = previous[i].X, previous[i].Y
+		width[i], height[i] = s.logicalSize(previous[i])
+		newWidth[i], newHeight[i] = s.logicalSize(configs[i])
+	}
+	newX := followEdges(x, width, newWidth, func(i, j int) bool {
+		return y[i] < y[j]+height[j] && y[j] < y[i]+height[i]
+	})
+	newY := followEdges(y, height, newHeight, func(i, j int) bool {
+		return x[i] < x[j]+width[j] && x[j] < x[i]+width[i]
+	})
+	for i := range configs {
+		configs[i].X, configs[i].Y = newX[i], newY[i]
+	}
+	shiftToOrigin(configs)
+}
+
+// followEdges returns the new start of every span along one axis. A span that
+// started where others ended, and overlaps them across the axis, starts where
+// the furthest of them ends now. The other spans keep their start.
+func followEdges(starts, sizes, newSizes []int32, across func(i, j int) bool) []int32 {
+	order := make([]int, len(starts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return starts[order[a]] < starts[order[b]] })
+
+	moved := make([]int32, len(starts))
+	for n, i := range order {
+		moved[i] = starts[i]
+		follows := false
+		for _, j := range order[:n] {
+			if starts[j]+sizes[j] != starts[i] || !across(i, j) {
+				continue
+			}
+			if edge := moved[j] + newSizes[j]; !follows || edge > moved[i] {
+				moved[i], follows = edge, true
+			}
+		}
+	}
+	return moved
+}
+
+// shiftToOrigin moves the logical monitors so that the desktop starts at 0,0
+func shiftToOrigin(configs []mutterLogicalMonitorConfig) {
+	var minX, minY int32
+	first := true
+	for _, config := range configs {
+		if len(config.Monitors) == 0 {
+			continue
+		}
+		if first || config.X < minX {
+			minX = config.X
+		}
+		if first || config.Y < minY {
+			minY = config.Y
+		}
+		first = false
+	}
+	for i := range configs {
+		configs[i].X -= minX
+		configs[i].Y -= minY
+	}
+}
+
+// applyConfig calls ApplyMonitorsConfig with the given method
+func (b *MutterBackend) applyConfig(changes []ModeChange, method uint32) error {
+	state, err := b.getState()
+	if err != nil {
+		return err
+	}
+	configs, err := b.buildConfig(state, changes)
+	if err != nil {
+		return err
+	}
+	return b.applyLogicalMonitors(state, configs, method)
+}
+
+// applyLogicalMonitors sends a complete layout to ApplyMonitorsConfig
+func (b *MutterBackend) applyLogicalMonitors(state *mutterState, configs []mutterLogicalMonitorConfig, method uint32) error {
+	conn, err := b.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	call := conn.Object(mutterBusName, mutterPath).Call(mutterInterface+".ApplyMonitorsConfig", 0,
+		state.Serial, method, configs, map[string]dbus.Variant{})
+	if call.Err != nil {
+		return fmt.Errorf("ApplyMonitorsConfig failed: %v", call.Err)
+	}
+	return nil
+}
+
+// LayoutScale returns the scale the connector would have in the mode, which sizes
+// the logical monitors in the logical layout mode only
+func (b *MutterBackend) LayoutScale(deviceName string, mode Mode) (float64, error) {
+	state, err := b.getState()
+	if err != nil {
+		return 0, err
+	}
+	if state.layoutMode() != MUTTER_LAYOUT_LOGICAL {
+		return 1, nil
+	}
+	monitor, err := state.findMonitor(deviceName)
+	if err != nil {
+		return 0, err
+	}
+	target, err := monitor.findMode(mode)
+	if err != nil {
+		return 0, err
+	}
+	for _, lm := range state.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			if spec.Connector == deviceName {
+				return target.fitScale(lm.Scale), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("monitor %s is not part of the current layout", deviceName)
+}
+
+// TestMode asks Mutter to verify the configuration without applying it
+func (b *MutterBackend) TestMode(change ModeChange) error {
+	return b.TestModes([]ModeChange{change})
+}
+
+// TestModes asks Mutter to verify the layout with every change at once, a
+// single change can leave the other monitors in a layout Mutter rejects
+func (b *MutterBackend) TestModes(changes []ModeChange) error {
+	return b.applyConfig(changes, MUTTER_METHOD_VERIFY)
+}
+
+// applyMethod returns the method used for real changes, persistent unless Temporary is set
+func (b *MutterBackend) applyMethod() uint32 {
+	if b.Temporary {
+		return MUTTER_METHOD_TEMPORARY
+	}
+	return MUTTER_METHOD_PERSISTENT
+}
+
+// SetTemporary switches between the temporary and the persistent method
+func (b *MutterBackend) SetTemporary(temporary bool) {
+	b.Temporary = temporary
+}
+
+// ApplyModes applies every change in one configuration
+func (b *MutterBackend) ApplyModes(changes []ModeChange) error {
+	return b.applyConfig(changes, b.applyMethod())
+}
+
+// layoutMode returns the layout-mode property, Mutter assumes logical when it is missing
+func (s *mutterState) layoutMode() uint32 {
+	if v, ok := s.Properties["layout-mode"]; ok {
+		if mode, ok := v.Value().(uint32); ok {
+			return mode
+		}
+	}
+	return MUTTER_LAYOUT_LOGICAL
+}
+
+// logicalSize returns the width and height a logical monitor takes on the
+// desktop, which only shrink with the scale in the logical layout mode
+func (s *mutterState) logicalSize(config mutterLogicalMonitorConfig) (int32, int32) {
+	if len(config.Monitors) == 0 {
+		return 0, 0
+	}
+	monitor, err := s.findMonitor(config.Monitors[0].Connector)
+	if err != nil {
+		return 0, 0
+	}
+	mode := monitor.modeByID(config.Monitors[0].ModeID)
+	if mode == nil {
+		return 0, 0
+	}
+	width, height := mode.Width, mode.Height
+	if Orientation(config.Transform % 4).SwapsAxes() {
+		width, height = height, width
+	}
+	if s.layoutMode() != MUTTER_LAYOUT_LOGICAL || config.Scale <= 0 {
+		return width, height
+	}
+	return int32(math.Round(float64(width) / config.Scale)), int32(math.Round(float64(height) / config.Scale))
+}
+
+// SetEnabled drops the monitors to switch off from the layout, closing the gaps
+// they leave, and appends the monitors to switch on to its right edge with their
+// preferred mode
+func (b *MutterBackend) SetEnabled(states map[string]bool) error {
+	state, err := b.getState()
+	if err != nil {
+		return err
+	}
+	current, err := b.buildConfig(state, nil)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]mutterLogicalMonitorConfig, len(current))
+	for i, config := range current {
+		kept[i] = config
+		kept[i].Monitors = nil
+		for _, assignment := range config.Monitors {
+			monitor, err := state.findMonitor(assignment.Connector)
+			if err != nil {
+				return err
+			}
+			if on, ok := states[monitor.monitorID()]; ok && !on {
+				continue
+			}
+			kept[i].Monitors = append(kept[i].Monitors, assignment)
+		}
+	}
+	state.relayout(current, kept)
+
+	var configs []mutterLogicalMonitorConfig
+	var right, top int32
+	hasPrimary := false
+	for _, config := range kept {
+		if len(config.Monitors) == 0 {
+			continue
+		}
+		configs = append(configs, config)
+		hasPrimary = hasPrimary || config.Primary
+		width, _ := state.logicalSize(config)
+		if edge := config.X + width; edge > right {
+			right, top = edge, config.Y
+		}
+	}
+
+	for i := range state.Monitors {
+		monitor := &state.Monitors[i]
+		if monitor.currentModeID() != "" || !states[monitor.monitorID()] {
+			continue
+		}
+		mode, err := monitor.preferredMode()
+		if err != nil {
+			return err
+		}
+		config := mutterLogicalMonitorConfig{
+			X:     right,
+			Y:     top,
+			Scale: mode.PreferredScale,
+			Monitors: []mutterMonitorAssignment{{
+				Connector:  monitor.Spec.Connector,
+				ModeID:     mode.ID,
+				Properties: map[string]dbus.Variant{},
+			}},
+		}
+		if config.Scale == 0 {
+			config.Scale = 1
+		}
+		configs = append(configs, config)
+		width, _ := state.logicalSize(config)
+		right += width
+	}
+	if len(configs) == 0 {
+		return fmt.Errorf("at least one monitor has to stay enabled")
+	}
+	// Mutter needs a primary monitor, hand it over when it was switched off
+	if !hasPrimary {
+		configs[0].Primary = true
+	}
+	return b.applyLogicalMonitors(state, configs, b.applyMethod())
+}
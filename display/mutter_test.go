@@ -0,0 +1,518 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestMutterLogicalSize(t *testing.T) {
+	monitors := []mutterMonitor{{
+		Spec:  mutterMonitorSpec{Connector: "eDP-1"},
+		Modes: []mutterMode{{ID: "2880x1800@60", Width: 2880, Height: 1800, Refresh: 60}},
+	}}
+	tests := []struct {
+		name       string
+		properties map[string]dbus.Variant
+		scale      float64
+		transform  uint32
+		wantWidth  int32
+		wantHeight int32
+	}{
+		{name: "logical layout is scaled", properties: map[string]dbus.Variant{"layout-mode": dbus.MakeVariant(uint32(MUTTER_LAYOUT_LOGICAL))}, scale: 2, wantWidth: 1440, wantHeight: 900},
+		{name: "missing layout mode is logical", scale: 1.5, wantWidth: 1920, wantHeight: 1200},
+		{name: "physical layout is not scaled", properties: map[string]dbus.Variant{"layout-mode": dbus.MakeVariant(uint32(MUTTER_LAYOUT_PHYSICAL))}, scale: 2, wantWidth: 2880, wantHeight: 1800},
+		{name: "rotated", scale: 2, transform: uint32(OrientationPortrait), wantWidth: 900, wantHeight: 1440},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &mutterState{Monitors: monitors, Properties: tt.properties}
+			config := mutterLogicalMonitorConfig{
+				Scale:     tt.scale,
+				Transform: tt.transform,
+				Monitors:  []mutterMonitorAssignment{{Connector: "eDP-1", ModeID: "2880x1800@60"}},
+			}
+			if width, height := state.logicalSize(config); width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("logicalSize = %dx%d, want %dx%d", width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestMutterFitScale(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  mutterMode
+		scale float64
+		want  float64
+	}{
+		{name: "supported scale is kept", mode: mutterMode{PreferredScale: 1, SupportedScales: []float64{1, 2}}, scale: 2, want: 2},
+		{name: "preferred scale", mode: mutterMode{PreferredScale: 1, SupportedScales: []float64{1, 1.25}}, scale: 2, want: 1},
+		{name: "closest supported scale", mode: mutterMode{PreferredScale: 3, SupportedScales: []float64{1, 1.5}}, scale: 2, want: 1.5},
+		{name: "no supported scales", mode: mutterMode{}, scale: 2, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mode.fitScale(tt.scale); got != tt.want {
+				t.Errorf("fitScale(%g) = %g, want %g", tt.scale, got, tt.want)
+			}
+		})
+	}
+}
+
+// mutterStub serves org.gnome.Mutter.DisplayConfig on a private bus, it checks
+// and records the layouts sent to ApplyMonitorsConfig without applying them
+type mutterStub struct {
+	mu      sync.Mutex
+	state   mutterState
+	applied []mutterApplyCall
+}
+
+// mutterApplyCall is a call to ApplyMonitorsConfig received by the stub
+type mutterApplyCall struct {
+	Method  uint32
+	Configs []mutterLogicalMonitorConfig
+}
+
+func (s *mutterStub) GetCurrentState() (uint32, []mutterMonitor, []mutterLogicalMonitor, map[string]dbus.Variant, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Serial, s.state.Monitors, s.state.LogicalMonitors, s.state.Properties, nil
+}
+
+func (s *mutterStub) ApplyMonitorsConfig(serial uint32, method uint32, configs []mutterLogicalMonitorConfig, properties map[string]dbus.Variant) *dbus.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Mutter rejects layouts built from an outdated state
+	if serial != s.state.Serial {
+		return dbus.MakeFailedError(fmt.Errorf("the requested configuration is based on stale information"))
+	}
+	if err := s.validate(configs); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	s.applied = append(s.applied, mutterApplyCall{Method: method, Configs: configs})
+	return nil
+}
+
+// validate rejects the layouts Mutter rejects: unknown modes, scales the mode
+// does not support, and logical monitors that overlap, leave gaps or do not
+// start at 0,0
+func (s *mutterStub) validate(configs []mutterLogicalMonitorConfig) error {
+	rects := make(map[string]*layoutRect)
+	var order []string
+	minX, minY := int32(math.MaxInt32), int32(math.MaxInt32)
+	for _, config := range configs {
+		var width, height int32
+		for _, assignment := range config.Monitors {
+			monitor, err := s.state.findMonitor(assignment.Connector)
+			if err != nil {
+				return err
+			}
+			mode := monitor.modeByID(assignment.ModeID)
+			if mode == nil {
+				return fmt.Errorf("invalid mode %s for %s", assignment.ModeID, assignment.Connector)
+			}
+			if !mode.supportsScale(config.Scale) {
+				return fmt.Errorf("scale %g not supported by %s in mode %s", config.Scale, assignment.Connector, mode.ID)
+			}
+			width, height = mode.Width, mode.Height
+		}
+		if config.Transform%2 == 1 {
+			width, height = height, width
+		}
+		if s.state.layoutMode() == MUTTER_LAYOUT_LOGICAL {
+			width = int32(math.Round(float64(width) / config.Scale))
+			height = int32(math.Round(float64(height) / config.Scale))
+		}
+		name := config.Monitors[0].Connector
+		rects[name] = &layoutRect{DeviceName: name, Width: width, Height: height, Position: &Position{X: config.X, Y: config.Y}}
+		order = append(order, name)
+		if config.X < minX {
+			minX = config.X
+		}
+		if config.Y < minY {
+			minY = config.Y
+		}
+	}
+	if len(order) == 0 {
+		return fmt.Errorf("no logical monitors")
+	}
+	if minX != 0 || minY != 0 {
+		return fmt.Errorf("the layout starts at %d,%d instead of 0,0", minX, minY)
+	}
+	return checkLayout(rects, order)
+}
+
+// calls returns the layouts received so far
+func (s *mutterStub) calls() []mutterApplyCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]mutterApplyCall(nil), s.applied...)
+}
+
+// startTestBus runs a private dbus-daemon for the test and returns its address
+func startTestBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	// Not t.TempDir, D-Bus addresses cannot hold the commas of test names and
+	// socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "wrm-dbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	// The session configuration lets every client own names and call each other
+	address := "unix:path=" + filepath.Join(dir, "bus")
+	cmd := exec.Command(daemon, "--session", "--address="+address, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	// The address is printed once the bus accepts connections
+	printed, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon did not start: %v", err)
+	}
+	return strings.TrimSpace(printed)
+}
+
+// newMutterStub serves a laptop panel at scale 2 with a 144 Hz portrait monitor on its
+// right and a switched off TV, and returns a backend talking to it
+func newMutterStub(t *testing.T) (*MutterBackend, *mutterStub) {
+	t.Helper()
+	address := startTestBus(t)
+	current := map[string]dbus.Variant{"is-current": dbus.MakeVariant(true)}
+	preferred := map[string]dbus.Variant{"is-preferred": dbus.MakeVariant(true)}
+	both := map[string]dbus.Variant{"is-current": dbus.MakeVariant(true), "is-preferred": dbus.MakeVariant(true)}
+	stub := &mutterStub{state: mutterState{
+		Serial: 7,
+		Monitors: []mutterMonitor{
+			{
+				Spec: mutterMonitorSpec{Connector: "eDP-1", Vendor: "BOE", Product: "0x0a1c", Serial: "0x00000000"},
+				Modes: []mutterMode{
+					{ID: "2880x1800@60", Width: 2880, Height: 1800, Refresh: 60.001, PreferredScale: 2, SupportedScales: []float64{1, 2}, Properties: both},
+					{ID: "1920x1200@60", Width: 1920, Height: 1200, Refresh: 59.95, PreferredScale: 1, SupportedScales: []float64{1}, Properties: map[string]dbus.Variant{}},
+				},
+				Properties: map[string]dbus.Variant{"display-name": dbus.MakeVariant("Built-in display"), "is-builtin": dbus.MakeVariant(true)},
+			},
+			{
+				Spec: mutterMonitorSpec{Connector: "DP-1", Vendor: "AOC", Product: "27G2G5", Serial: "1A2B3C4D5E6F"},
+				Modes: []mutterMode{
+					{ID: "1920x1080@144.004", Width: 1920, Height: 1080, Refresh: 144.004, PreferredScale: 1, SupportedScales: []float64{1}, Properties: current},
+					{ID: "1920x1080@60", Width: 1920, Height: 1080, Refresh: 60, PreferredScale: 1, SupportedScales: []float64{1}, Properties: preferred},
+				},
+				Properties: map[string]dbus.Variant{"display-name": dbus.MakeVariant("AOC 27\"")},
+			},
+			{
+				Spec: mutterMonitorSpec{Connector: "HDMI-1", Vendor: "GSM", Product: "LG TV SSCR2", Serial: "0x01010101"},
+				Modes: []mutterMode{
+					{ID: "3840x2160@60", Width: 3840, Height: 2160, Refresh: 60, PreferredScale: 1.5, SupportedScales: []float64{1, 1.5, 2}, Properties: preferred},
+				},
+				Properties: map[string]dbus.Variant{},
+			},
+		},
+		LogicalMonitors: []mutterLogicalMonitor{
+			{X: 0, Y: 0, Scale: 2, Primary: true, Monitors: []mutterMonitorSpec{{Connector: "eDP-1", Vendor: "BOE", Product: "0x0a1c", Serial: "0x00000000"}}, Properties: map[string]dbus.Variant{}},
+			{X: 1440, Y: 0, Scale: 1, Transform: 1, Monitors: []mutterMonitorSpec{{Connector: "DP-1", Vendor: "AOC", Product: "27G2G5", Serial: "1A2B3C4D5E6F"}}, Properties: map[string]dbus.Variant{}},
+		},
+		Properties: map[string]dbus.Variant{"layout-mode": dbus.MakeVariant(uint32(MUTTER_LAYOUT_LOGICAL))},
+	}}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Export(stub, mutterPath, mutterInterface); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := conn.RequestName(mutterBusName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own %s: %v", mutterBusName, err)
+	}
+	return &MutterBackend{BusAddress: address}, stub
+}
+
+// describeLayout summarizes the logical monitors sent to ApplyMonitorsConfig,
+// e.g. "0,0 x2 transform 0 primary eDP-1=2880x1800@60"
+func describeLayout(configs []mutterLogicalMonitorConfig) []string {
+	var layout []string
+	for _, config := range configs {
+		line := fmt.Sprintf("%d,%d x%g transform %d", config.X, config.Y, config.Scale, config.Transform)
+		if config.Primary {
+			line += " primary"
+		}
+		for _, m := range config.Monitors {
+			line += fmt.Sprintf(" %s=%s", m.Connector, m.ModeID)
+		}
+		layout = append(layout, line)
+	}
+	return layout
+}
+
+func TestMutterQueries(t *testing.T) {
+	b, _ := newMutterStub(t)
+
+	monitors, err := b.ListAllMonitors()
+	if err != nil {
+		t.Fatalf("ListAllMonitors failed: %v", err)
+	}
+	want := []MonitorInfo{
+		{Id: 0, FriendlyName: "Built-in display", DeviceName: "eDP-1", MonitorID: "BOE-0x0a1c-eDP-1"},
+		{Id: 1, FriendlyName: "AOC 27\"", DeviceName: "DP-1", MonitorID: "AOC-27G2G5-DP-1"},
+		{Id: 2, FriendlyName: "LG TV SSCR2", DeviceName: "HDMI-1", MonitorID: "GSM-LG_TV_SSCR2-HDMI-1", Status: MONITOR_STATUS_DISABLED},
+	}
+	if !reflect.DeepEqual(monitors, want) {
+		t.Errorf("ListAllMonitors = %+v\nwant %+v", monitors, want)
+	}
+
+	tests := []struct {
+		device          string
+		wantMode        Mode
+		wantPosition    Position
+		wantOrientation Orientation
+		wantScale       float64
+		wantErr         bool
+	}{
+		{
+			device:    "eDP-1",
+			wantMode:  Mode{Width: 2880, Height: 1800, Frequency: 60, Rate: RefreshRate{Numerator: 60001, Denominator: 1000}},
+			wantScale: 2,
+		},
+		{
+			device:          "DP-1",
+			wantMode:        Mode{Width: 1920, Height: 1080, Frequency: 144, Rate: RefreshRate{Numerator: 144004, Denominator: 1000}},
+			wantPosition:    Position{X: 1440},
+			wantOrientation: OrientationPortrait,
+			wantScale:       1,
+		},
+		{device: "HDMI-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.device, func(t *testing.T) {
+			mode, err := b.CurrentMode(tt.device)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("CurrentMode of a switched off monitor = %v", mode)
+				}
+				return
+			}
+			if err != nil || mode != tt.wantMode {
+				t.Errorf("CurrentMode = %v, %v, want %v", mode, err, tt.wantMode)
+			}
+			if position, err := b.CurrentPosition(tt.device); err != nil || position != tt.wantPosition {
+				t.Errorf("CurrentPosition = %v, %v, want %v", position, err, tt.wantPosition)
+			}
+			if orientation, err := b.CurrentOrientation(tt.device); err != nil || orientation != tt.wantOrientation {
+				t.Errorf("CurrentOrientation = %v, %v, want %v", orientation, err, tt.wantOrientation)
+			}
+			if scale, err := b.LayoutScale(tt.device, tt.wantMode); err != nil || scale != tt.wantScale {
+				t.Errorf("LayoutScale = %g, %v, want %g", scale, err, tt.wantScale)
+			}
+		})
+	}
+
+	// The smaller panel mode does not support scale 2
+	panel := Mode{Width: 1920, Height: 1200, Frequency: 60, Rate: RefreshRate{Numerator: 59950, Denominator: 1000}}
+	if scale, err := b.LayoutScale("eDP-1", panel); err != nil || scale != 1 {
+		t.Errorf("LayoutScale at %v = %g, %v, want 1", panel, scale, err)
+	}
+}
+
+func TestMutterApplyModes(t *testing.T) {
+	sixty := Mode{Width: 1920, Height: 1080, Frequency: 60, Rate: RefreshRate{Numerator: 60000, Denominator: 1000}}
+	panel := Mode{Width: 1920, Height: 1200, Frequency: 60, Rate: RefreshRate{Numerator: 59950, Denominator: 1000}}
+	landscape := OrientationLandscape
+	tests := []struct {
+		name       string
+		temporary  bool
+		test       bool // Verify the changes through the package instead of applying them
+		changes    []ModeChange
+		wantMethod uint32
+		wantLayout []string
+		wantErr    bool
+	}{
+		{
+			name:       "persistent",
+			changes:    []ModeChange{{DeviceName: "DP-1", Mode: sixty}},
+			wantMethod: MUTTER_METHOD_PERSISTENT,
+			wantLayout: []string{"0,0 x2 transform 0 primary eDP-1=2880x1800@60", "1440,0 x1 transform 1 DP-1=1920x1080@60"},
+		},
+		{
+			name:       "temporary",
+			temporary:  true,
+			changes:    []ModeChange{{DeviceName: "DP-1", Mode: sixty}},
+			wantMethod: MUTTER_METHOD_TEMPORARY,
+			wantLayout: []string{"0,0 x2 transform 0 primary eDP-1=2880x1800@60", "1440,0 x1 transform 1 DP-1=1920x1080@60"},
+		},
+		{
+			name:       "verified only",
+			test:       true,
+			changes:    []ModeChange{{DeviceName: "DP-1", Mode: sixty}},
+			wantMethod: MUTTER_METHOD_VERIFY,
+			wantLayout: []string{"0,0 x2 transform 0 primary eDP-1=2880x1800@60", "1440,0 x1 transform 1 DP-1=1920x1080@60"},
+		},
+		{
+			name: "moved, rotated and primary",
+			changes: []ModeChange{{
+				DeviceName:  "DP-1",
+				Mode:        sixty,
+				Position:    &Position{X: -1920, Y: 0},
+				Orientation: &landscape,
+				Primary:     true,
+			}},
+			wantMethod: MUTTER_METHOD_PERSISTENT,
+			wantLayout: []string{"1920,0 x2 transform 0 eDP-1=2880x1800@60", "0,0 x1 transform 0 primary DP-1=1920x1080@60"},
+		},
+		{
+			// Scale 2 is not supported at 1920x1200, the wider panel pushes the portrait monitor along
+			name:       "new scale and size",
+			changes:    []ModeChange{{DeviceName: "eDP-1", Mode: panel}},
+			wantMethod: MUTTER_METHOD_PERSISTENT,
+			wantLayout: []string{"0,0 x1 transform 0 primary eDP-1=1920x1200@60", "1920,0 x1 transform 1 DP-1=1920x1080@144.004"},
+		},
+		{
+			name: "every change is verified together",
+			test: true,
+			changes: []ModeChange{
+				{DeviceName: "eDP-1", Mode: panel},
+				{DeviceName: "DP-1", Mode: sixty},
+			},
+			wantMethod: MUTTER_METHOD_VERIFY,
+			wantLayout: []string{"0,0 x1 transform 0 primary eDP-1=1920x1200@60", "1920,0 x1 transform 1 DP-1=1920x1080@60"},
+		},
+		{
+			name:    "mode the monitor does not have",
+			changes: []ModeChange{{DeviceName: "DP-1", Mode: Mode{Width: 800, Height: 600, Frequency: 60}}},
+			wantErr: true,
+		},
+		{
+			name:    "switched off monitor",
+			changes: []ModeChange{{DeviceName: "HDMI-1", Mode: Mode{Width: 3840, Height: 2160, Frequency: 60, Rate: RefreshRate{Numerator: 60000, Denominator: 1000}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, stub := newMutterStub(t)
+			useBackend(t, b)
+			b.SetTemporary(tt.temporary)
+			var err error
+			if tt.test {
+				err = testChanges(tt.changes)
+			} else {
+				err = b.ApplyModes(tt.changes)
+			}
+			calls := stub.calls()
+			if tt.wantErr {
+				if err == nil || len(calls) != 0 {
+					t.Errorf("ApplyModes = %v with %d calls, want an error before calling Mutter", err, len(calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyModes failed: %v", err)
+			}
+			if len(calls) != 1 {
+				t.Fatalf("%d calls to ApplyMonitorsConfig, want 1", len(calls))
+			}
+			if calls[0].Method != tt.wantMethod {
+				t.Errorf("method = %d, want %d", calls[0].Method, tt.wantMethod)
+			}
+			if layout := describeLayout(calls[0].Configs); !reflect.DeepEqual(layout, tt.wantLayout) {
+				t.Errorf("layout = %q\nwant %q", layout, tt.wantLayout)
+			}
+		})
+	}
+}
+
+func TestMutterSetEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		states     map[string]bool
+		wantLayout []string
+		wantErr    bool
+	}{
+		{
+			// The portrait monitor is 1080 wide, the TV takes its preferred scale
+			name:       "switch on",
+			states:     map[string]bool{"GSM-LG_TV_SSCR2-HDMI-1": true},
+			wantLayout: []string{"0,0 x2 transform 0 primary eDP-1=2880x1800@60", "1440,0 x1 transform 1 DP-1=1920x1080@144.004", "2520,0 x1.5 transform 0 HDMI-1=3840x2160@60"},
+		},
+		{
+			// The portrait monitor takes the place of the panel
+			name:       "primary switched off",
+			states:     map[string]bool{"BOE-0x0a1c-eDP-1": false},
+			wantLayout: []string{"0,0 x1 transform 1 primary DP-1=1920x1080@144.004"},
+		},
+		{
+			name:       "swapped",
+			states:     map[string]bool{"BOE-0x0a1c-eDP-1": false, "GSM-LG_TV_SSCR2-HDMI-1": true},
+			wantLayout: []string{"0,0 x1 transform 1 primary DP-1=1920x1080@144.004", "1080,0 x1.5 transform 0 HDMI-1=3840x2160@60"},
+		},
+		{
+			name:    "last monitors switched off",
+			states:  map[string]bool{"BOE-0x0a1c-eDP-1": false, "AOC-27G2G5-DP-1": false},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, stub := newMutterStub(t)
+			err := b.SetEnabled(tt.states)
+			calls := stub.calls()
+			if tt.wantErr {
+				if err == nil || len(calls) != 0 {
+					t.Errorf("SetEnabled = %v with %d calls, want an error before calling Mutter", err, len(calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetEnabled failed: %v", err)
+			}
+			if len(calls) != 1 {
+				t.Fatalf("%d calls to ApplyMonitorsConfig, want 1", len(calls))
+			}
+			if layout := describeLayout(calls[0].Configs); !reflect.DeepEqual(layout, tt.wantLayout) {
+				t.Errorf("layout = %q\nwant %q", layout, tt.wantLayout)
+			}
+		})
+	}
+}
+
+func TestMutterStaleState(t *testing.T) {
+	b, stub := newMutterStub(t)
+	state, err := b.getState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another client changed the layout in the meantime
+	stub.mu.Lock()
+	stub.state.Serial++
+	stub.mu.Unlock()
+	configs, err := b.buildConfig(state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.applyLogicalMonitors(state, configs, MUTTER_METHOD_PERSISTENT); err == nil || !strings.Contains(err.Error(), "ApplyMonitorsConfig failed") {
+		t.Errorf("applyLogicalMonitors = %v, want the Mutter error", err)
+	}
+}
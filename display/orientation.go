@@ -0,0 +1,77 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Orientation is the counterclockwise rotation of a monitor. The values match
+// the DMDO_* constants of DEVMODE and the transforms of Mutter and Wayland.
+type Orientation uint32
+
+const (
+	OrientationLandscape        Orientation = 0 // 0 degrees
+	OrientationPortrait         Orientation = 1 // 90 degrees
+	OrientationLandscapeFlipped Orientation = 2 // 180 degrees
+	OrientationPortraitFlipped  Orientation = 3 // 270 degrees
+)
+
+// orientationNames maps every accepted spelling to its orientation
+var orientationNames = map[string]Orientation{
+	"0":                 OrientationLandscape,
+	"landscape":         OrientationLandscape,
+	"normal":            OrientationLandscape,
+	"90":                OrientationPortrait,
+	"portrait":          OrientationPortrait,
+	"180":               OrientationLandscapeFlipped,
+	"flipped":           OrientationLandscapeFlipped,
+	"landscape-flipped": OrientationLandscapeFlipped,
+	"270":               OrientationPortraitFlipped,
+	"portrait-flipped":  OrientationPortraitFlipped,
+}
+
+// ParseOrientation parses an orientation given in degrees (0, 90, 180, 270,
+// optionally written 90deg) or by name (landscape, portrait, flipped, portrait-flipped)
+func ParseOrientation(value string) (Orientation, error) {
+	o, ok := orientationNames[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "deg")]
+	if !ok {
+		return 0, fmt.Errorf("invalid orientation '%s'. Use 0, 90, 180, 270, landscape, portrait, flipped or portrait-flipped", value)
+	}
+	return o, nil
+}
+
+// Degrees returns the rotation in degrees
+func (o Orientation) Degrees() uint32 {
+	return uint32(o%4) * 90
+}
+
+func (o Orientation) String() string {
+	switch o {
+	case OrientationPortrait:
+		return "portrait"
+	case OrientationLandscapeFlipped:
+		return "flipped"
+	case OrientationPortraitFlipped:
+		return "portrait-flipped"
+	}
+	return "landscape"
+}
+
+// SwapsAxes reports whether the desktop sees the monitor with width and height swapped
+func (o Orientation) SwapsAxes() bool {
+	return o == OrientationPortrait || o == OrientationPortraitFlipped
+}
+
+// OrientationReader is implemented by backends that can report the rotation of a monitor
+type OrientationReader interface {
+	CurrentOrientation(deviceName string) (Orientation, error)
+}
+
+// CurrentOrientation returns the rotation of the device, landscape when the backend cannot tell
+func CurrentOrientation(deviceName string) (Orientation, error) {
+	reader, ok := CurrentBackend().(OrientationReader)
+	if !ok {
+		return OrientationLandscape, nil
+	}
+	return reader.CurrentOrientation(deviceName)
+}
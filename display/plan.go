@@ -0,0 +1,55 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// ErrDryRun is returned instead of applying a change while dry-run is on
+var ErrDryRun = errors.New("dry run, nothing was changed")
+
+var dryRun bool
+
+// SetDryRun makes changes only print what they would do, after the driver has tested the modes
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// DryRun reports whether changes are only planned
+func DryRun() bool {
+	return dryRun
+}
+
+// PrintPlan tests every change with the backend, like before applying it, and
+// prints a table of the current and target modes with the verdict of the test.
+// Nothing is applied. It fails when one of the modes is rejected.
+func PrintPlan(changes []ModeChange) error {
+	backend := CurrentBackend()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MONITOR\tCURRENT\tTARGET\tTEST")
+	rejected := 0
+	for _, c := range changes {
+		current := "unknown"
+		if mode, err := backend.CurrentMode(c.DeviceName); err == nil {
+			orientation, _ := CurrentOrientation(c.DeviceName)
+			current = fmt.Sprintf("%s, %s", mode, orientation)
+			if pos, err := CurrentPosition(c.DeviceName); err == nil {
+				current += fmt.Sprintf(" at %d,%d", pos.X, pos.Y)
+			}
+		}
+
+		verdict := "ok"
+		if err := backend.TestMode(c); err != nil {
+			verdict = fmt.Sprintf("rejected: %v", err)
+			rejected++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.DeviceName, current, c.Describe(), verdict)
+	}
+	w.Flush()
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d modes were rejected by the driver", rejected, len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RefreshRate is an exact refresh rate, e.g. 60000/1001 for 59.94 Hz. The zero
+// value means the rate is unknown or, when selecting a mode, any rate.
+type RefreshRate struct {
+	Numerator   uint32 `json:"numerator"`
+	Denominator uint32 `json:"denominator"`
+}
+
+// IntegerRate returns the rate of a whole number of Hz, the zero value for 0
+func IntegerRate(hz uint32) RefreshRate {
+	if hz == 0 {
+		return RefreshRate{}
+	}
+	return RefreshRate{Numerator: hz, Denominator: 1}
+}
+
+// ParseRefreshRate parses a rate written as "60", "59.94" or "60000/1001", an "Hz" suffix is allowed
+func ParseRefreshRate(value string) (RefreshRate, error) {
+	value = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "hz")
+	invalid := fmt.Errorf("invalid refresh rate '%s'. Use e.g. 60, 59.94 or 60000/1001", value)
+
+	if numerator, denominator, found := strings.Cut(value, "/"); found {
+		n, err1 := strconv.ParseUint(numerator, 10, 32)
+		d, err2 := strconv.ParseUint(denominator, 10, 32)
+		if err1 != nil || err2 != nil || n == 0 || d == 0 {
+			return RefreshRate{}, invalid
+		}
+		return RefreshRate{Numerator: uint32(n), Denominator: uint32(d)}, nil
+	}
+
+	// Decimals are kept exact by scaling them to a power of ten
+	whole, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) > 6 {
+		return RefreshRate{}, invalid
+	}
+	n, err := strconv.ParseUint(whole+fraction, 10, 32)
+	if err != nil || n == 0 {
+		return RefreshRate{}, invalid
+	}
+	return RefreshRate{Numerator: uint32(n), Denominator: uint32(math.Pow10(len(fraction)))}, nil
+}
+
+// rateFromHz approximates a floating point rate, as reported by some backends, to the mHz
+func rateFromHz(hz float64) RefreshRate {
+	if hz <= 0 {
+		return RefreshRate{}
+	}
+	return RefreshRate{Numerator: uint32(math.Round(hz * 1000)), Denominator: 1000}
+}
+
+// IsZero reports whether the rate is unknown
+func (r RefreshRate) IsZero() bool {
+	return r.Numerator == 0 || r.Denominator == 0
+}
+
+// IsInteger reports whether the rate is a whole number of Hz
+func (r RefreshRate) IsInteger() bool {
+	return !r.IsZero() && r.Numerator%r.Denominator == 0
+}
+
+// Hz returns the rate as a floating point number
+func (r RefreshRate) Hz() float64 {
+	if r.IsZero() {
+		return 0
+	}
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
+// Matches reports whether both rates are the same to a hundredth of a Hz, so 59.94 matches 60000/1001
+func (r RefreshRate) Matches(other RefreshRate) bool {
+	return math.Abs(r.Hz()-other.Hz()) < 0.01
+}
+
+// wholeHz reports whether hz is the rate truncated, as Windows reports 59.94 Hz as 59, or rounded
+func (r RefreshRate) wholeHz(hz uint32) bool {
+	return hz == uint32(r.Hz()) || hz == uint32(math.Round(r.Hz()))
+}
+
+// String formats the rate with three decimals, e.g. "59.940 Hz"
+func (r RefreshRate) String() string {
+	return fmt.Sprintf("%.3f Hz", r.Hz())
+}
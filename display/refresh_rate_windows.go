@@ -0,0 +1,80 @@
+package display
+
+import "fmt"
+
+// activePathOf returns the index of the active path whose source is the GDI device
+func activePathOf(paths []DISPLAYCONFIG_PATH_INFO, deviceName string) (int, error) {
+	for i, path := range paths {
+		sourceDeviceName, err := GetSourceDeviceName(path.SourceInfo.AdapterId, path.SourceInfo.Id)
+		if err == nil && sourceDeviceName == deviceName {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no active display path for %s", deviceName)
+}
+
+// exactRefreshRate returns the vertical sync rate of the target mode driving the
+// device, which unlike DmDisplayFrequency tells 59.94 Hz and 60 Hz apart
+func exactRefreshRate(deviceName string) (RefreshRate, error) {
+	paths, modes, err := queryDisplayConfig(QDC_ONLY_ACTIVE_PATHS)
+	if err != nil {
+		return RefreshRate{}, err
+	}
+	i, err := activePathOf(paths, deviceName)
+	if err != nil {
+		return RefreshRate{}, err
+	}
+	target := paths[i].TargetInfo
+	if int(target.ModeInfoIdx) < len(modes) {
+		if mode, err := modes[target.ModeInfoIdx].TargetMode(); err == nil {
+			vSync := mode.TargetVideoSignalInfo.VSyncFreq
+			if vSync.Denominator != 0 {
+				return RefreshRate{Numerator: vSync.Numerator, Denominator: vSync.Denominator}, nil
+			}
+		}
+	}
+	// Fall back to the rate the path asked for
+	if target.RefreshRate.Denominator == 0 {
+		return RefreshRate{}, fmt.Errorf("no refresh rate reported for %s", deviceName)
+	}
+	return RefreshRate{Numerator: target.RefreshRate.Numerator, Denominator: target.RefreshRate.Denominator}, nil
+}
+
+// applyExactRefreshRates sets the fractional rates of the changes on their
+// display paths. DEVMODE only takes whole Hz, so this runs after the modes are
+// applied; the target modes are dropped so Windows picks ones matching the rate.
+// The flags decide whether the rates are saved to the display database.
+func applyExactRefreshRates(changes []ModeChange, flags uint32) error {
+	var exact []ModeChange
+	for _, c := range changes {
+		if !c.Mode.Rate.IsZero() && !c.Mode.Rate.IsInteger() {
+			exact = append(exact, c)
+		}
+	}
+	if len(exact) == 0 {
+		return nil
+	}
+	paths, modes, err := queryDisplayConfig(QDC_ONLY_ACTIVE_PATHS)
+	if err != nil {
+		return err
+	}
+	for _, c := range exact {
+		i, err := activePathOf(paths, c.DeviceName)
+		if err != nil {
+			return err
+		}
+		paths[i].TargetInfo.RefreshRate = DISPLAYCONFIG_RATIONAL{Numerator: c.Mode.Rate.Numerator, Denominator: c.Mode.Rate.Denominator}
+		paths[i].TargetInfo.ModeInfoIdx = DISPLAYCONFIG_PATH_MODE_IDX_INVALID
+	}
+
+	var modePtr *DISPLAYCONFIG_MODE_INFO
+	if len(modes) > 0 {
+		modePtr = &modes[0]
+	}
+	ret := SetDisplayConfig(uint32(len(paths)), &paths[0], uint32(len(modes)), modePtr,
+		SDC_APPLY|SDC_USE_SUPPLIED_DISPLAY_CONFIG|SDC_ALLOW_CHANGES|flags)
+	if ret != ERROR_SUCCESS {
+		return fmt.Errorf("SetDisplayConfig failed to set the exact refresh rate, error %d", ret)
+	}
+	return nil
+}
@@ -3,84 +3,18 @@ package display
 import (
 	"fmt"
 	"sort"
-	"strconv"
-	"strings"
-	"syscall"
-	"unsafe"
-)
-
-// DEVMODE structure
-type DEVMODE struct {
-	DmDeviceName         [32]uint16
-	DmSpecVersion        uint16
-	DmDriverVersion      uint16
-	DmSize               uint16
-	DmDriverExtra        uint16
-	DmFields             uint32
-	DmPosition           POINTL
-	DmDisplayOrientation uint32
-	DmDisplayFixedOutput uint32
-	DmColor              uint16
-	DmDuplex             uint16
-	DmYResolution        uint16
-	DmTTOption           uint16
-	DmCollate            uint16
-	DmFormName           [32]uint16
-	DmLogPixels          uint16
-	DmBitsPerPel         uint32
-	DmPelsWidth          uint32
-	DmPelsHeight         uint32
-	DmDisplayFlags       uint32
-	DmDisplayFrequency   uint32
-	DmICMMethod          uint32
-	DmICMIntent          uint32
-	DmMediaType          uint32
-	DmDitherType         uint32
-	DmReserved1          uint32
-	DmReserved2          uint32
-	DmPanningWidth       uint32
-	DmPanningHeight      uint32
-}
-
-// POINTL structure
-type POINTL struct {
-	X int32
-	Y int32
-}
-
-var (
-	enumDisplaySettingsExW = user32.NewProc("EnumDisplaySettingsExW")
 )
 
 // Resolution represents a display resolution
 type Resolution struct {
-	Width  uint32
-	Height uint32
+	Width      uint32
+	Height     uint32
+	Interlaced bool
 }
 
 // ListResolutions lists all available modes for a device
-func ListResolutions(deviceName string) ([]DEVMODE, error) {
-	var modes []DEVMODE
-	var iModeNum uint32 = 0
-	deviceNamePtr, _ := syscall.UTF16PtrFromString(deviceName)
-	for {
-		var devMode DEVMODE
-		devMode.DmSize = uint16(unsafe.Sizeof(devMode))
-		ret, _, _ := enumDisplaySettingsExW.Call(
-			uintptr(unsafe.Pointer(deviceNamePtr)),
-			uintptr(iModeNum),
-			uintptr(unsafe.Pointer(&devMode)),
-			0,
-		)
-		if ret == 0 {
-			break
-		}
-		modes = append(modes, devMode)
-		// Remove the print statement or keep it for debugging
-		// fmt.Printf("%v\n", devMode.DmDisplayFrequency)
-		iModeNum++
-	}
-	return modes, nil
+func ListResolutions(deviceName string) ([]Mode, error) {
+	return CurrentBackend().ListModes(deviceName)
 }
 
 // ListResolutionsForMonitor lists available resolutions for a monitor
@@ -105,14 +39,17 @@ func ListResolutionsForMonitor(monitorIndex int) {
 		return
 	}
 	fmt.Printf("Resolutions for %s :\n", mi.FriendlyName)
+	// Not every backend knows the current mode, the listing then goes without the marker
+	current, err := CurrentBackend().CurrentMode(deviceName)
+	knowsCurrent := err == nil
 
 	// Collect unique resolutions
 	resolutionMap := make(map[string]Resolution)
 	for _, mode := range modes {
-		resKey := fmt.Sprintf("%dx%d", mode.DmPelsWidth, mode.DmPelsHeight)
-		resolutionMap[resKey] = Resolution{
-			Width:  mode.DmPelsWidth,
-			Height: mode.DmPelsHeight,
+		resolutionMap[mode.Resolution()] = Resolution{
+			Width:      mode.Width,
+			Height:     mode.Height,
+			Interlaced: mode.Interlaced,
 		}
 	}
 
@@ -125,8 +62,8 @@ func ListResolutionsForMonitor(monitorIndex int) {
 	// Sort resolutions by their string representation in descending order
 	sort.Slice(resolutions, func(i, j int) bool {
 		// Create string representations
-		strI := fmt.Sprintf("%05dx%05d", resolutions[i].Width, resolutions[i].Height)
-		strJ := fmt.Sprintf("%05dx%05d", resolutions[j].Width, resolutions[j].Height)
+		strI := fmt.Sprintf("%05dx%05d%t", resolutions[i].Width, resolutions[i].Height, !resolutions[i].Interlaced)
+		strJ := fmt.Sprintf("%05dx%05d%t", resolutions[j].Width, resolutions[j].Height, !resolutions[j].Interlaced)
 
 		// Compare strings
 		if strI == strJ {
@@ -135,9 +72,16 @@ func ListResolutionsForMonitor(monitorIndex int) {
 		return strI > strJ // For descending order
 	})
 
-	// Print sorted resolutions
+	// Print sorted resolutions, marking the one in use
 	for i, res := range resolutions {
-		fmt.Printf("%3d. %dx%d\n", i+1, res.Width, res.Height)
+		line := fmt.Sprintf("%3d. %dx%d", i+1, res.Width, res.Height)
+		if res.Interlaced {
+			line += "i (interlaced)"
+		}
+		if knowsCurrent && res.Width == current.Width && res.Height == current.Height && res.Interlaced == current.Interlaced {
+			line += " (current)"
+		}
+		fmt.Println(line)
 	}
 }
 
@@ -147,17 +91,12 @@ func ValidateResolution(deviceName string, resolution string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	resParts := strings.Split(resolution, "x")
-	if len(resParts) != 2 {
-		return false, fmt.Errorf("invalid resolution format")
-	}
-	width, err1 := strconv.Atoi(resParts[0])
-	height, err2 := strconv.Atoi(resParts[1])
-	if err1 != nil || err2 != nil {
-		return false, fmt.Errorf("invalid resolution dimensions")
+	width, height, interlaced, err := ParseResolution(resolution)
+	if err != nil {
+		return false, err
 	}
 	for _, mode := range modes {
-		if int(mode.DmPelsWidth) == width && int(mode.DmPelsHeight) == height {
+		if mode.Width == width && mode.Height == height && mode.Interlaced == interlaced {
 			return true, nil
 		}
 	}
@@ -0,0 +1,92 @@
+package display
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureOutput returns what fn prints to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// useBackend makes b the active backend for the test
+func useBackend(t *testing.T, b Backend) {
+	t.Helper()
+	previous := activeBackend
+	SetBackend(b)
+	t.Cleanup(func() { SetBackend(previous) })
+}
+
+func TestListingsMarkCurrentMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		knowsCurrent bool
+		list         func()
+		want         []string
+	}{
+		{
+			name:         "resolutions",
+			knowsCurrent: true,
+			list:         func() { ListResolutionsForMonitor(0) },
+			want:         []string{"  1. 1920x1080 (current)", "  2. 1280x720\n"},
+		},
+		{
+			name:         "frequencies",
+			knowsCurrent: true,
+			list:         func() { ListFrequenciesForResolution(0, "1920x1080") },
+			want:         []string{"1. 60 Hz (32 bit) (current)", "2. 144 Hz (32 bit)\n"},
+		},
+		{
+			name: "resolutions without current mode",
+			list: func() { ListResolutionsForMonitor(0) },
+			want: []string{"  1. 1920x1080\n", "  2. 1280x720\n"},
+		},
+		{
+			name: "frequencies without current mode",
+			list: func() { ListFrequenciesForResolution(0, "1920x1080") },
+			want: []string{"1. 60 Hz (32 bit)\n", "2. 144 Hz (32 bit)\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeBackend()
+			f.AddMonitor("27G2G5", "DISPLAY1",
+				Mode{Width: 1920, Height: 1080, Frequency: 60, BitsPerPixel: 32},
+				Mode{Width: 1920, Height: 1080, Frequency: 144, BitsPerPixel: 32},
+				Mode{Width: 1280, Height: 720, Frequency: 60, BitsPerPixel: 32},
+			)
+			// Backends such as sysfs cannot tell the current mode
+			if !tt.knowsCurrent {
+				delete(f.Current, "DISPLAY1")
+			}
+			useBackend(t, f)
+
+			out := captureOutput(t, tt.list)
+			for _, line := range tt.want {
+				if !strings.Contains(out, line) {
+					t.Errorf("output lacks %q:\n%s", line, out)
+				}
+			}
+			if !tt.knowsCurrent && strings.Contains(out, "current") {
+				t.Errorf("output marks a current mode:\n%s", out)
+			}
+		})
+	}
+}
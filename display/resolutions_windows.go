@@ -0,0 +1,181 @@
+package display
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// DEVMODE structure
+type DEVMODE struct {
+	DmDeviceName         [32]uint16
+	DmSpecVersion        uint16
+	DmDriverVersion      uint16
+	DmSize               uint16
+	DmDriverExtra        uint16
+	DmFields             uint32
+	DmPosition           POINTL
+	DmDisplayOrientation uint32
+	DmDisplayFixedOutput uint32
+	DmColor              uint16
+	DmDuplex             uint16
+	DmYResolution        uint16
+	DmTTOption           uint16
+	DmCollate            uint16
+	DmFormName           [32]uint16
+	DmLogPixels          uint16
+	DmBitsPerPel         uint32
+	DmPelsWidth          uint32
+	DmPelsHeight         uint32
+	DmDisplayFlags       uint32
+	DmDisplayFrequency   uint32
+	DmICMMethod          uint32
+	DmICMIntent          uint32
+	DmMediaType          uint32
+	DmDitherType         uint32
+	DmReserved1          uint32
+	DmReserved2          uint32
+	DmPanningWidth       uint32
+	DmPanningHeight      uint32
+}
+
+// POINTL structure
+type POINTL struct {
+	X int32
+	Y int32
+}
+
+var (
+	enumDisplaySettingsExW = user32.NewProc("EnumDisplaySettingsExW")
+)
+
+const (
+	ENUM_CURRENT_SETTINGS  = 0xFFFFFFFF
+	ENUM_REGISTRY_SETTINGS = 0xFFFFFFFE
+
+	DM_POSITION           = 0x00000020
+	DM_DISPLAYORIENTATION = 0x00000080
+	DM_BITSPERPEL         = 0x00040000
+	DM_DISPLAYFLAGS       = 0x00200000
+
+	// DmDisplayFlags bits
+	DM_INTERLACED       = 0x00000002
+	DM_PELSWIDTH        = 0x00080000
+	DM_PELSHEIGHT       = 0x00100000
+	DM_DISPLAYFREQUENCY = 0x00400000
+)
+
+// EnumDisplaySettingsEx wraps the Windows API call
+func EnumDisplaySettingsEx(deviceName *uint16, iModeNum uint32, lpDevMode *DEVMODE, dwFlags uint32) bool {
+	ret, _, _ := enumDisplaySettingsExW.Call(
+		uintptr(unsafe.Pointer(deviceName)),
+		uintptr(iModeNum),
+		uintptr(unsafe.Pointer(lpDevMode)),
+		uintptr(dwFlags),
+	)
+	return ret != 0
+}
+
+// EnumDevModes lists all available DEVMODE entries for a device
+func EnumDevModes(deviceName string) ([]DEVMODE, error) {
+	var modes []DEVMODE
+	var iModeNum uint32 = 0
+	deviceNamePtr, _ := syscall.UTF16PtrFromString(deviceName)
+	for {
+		var devMode DEVMODE
+		devMode.DmSize = uint16(unsafe.Sizeof(devMode))
+		if !EnumDisplaySettingsEx(deviceNamePtr, iModeNum, &devMode, 0) {
+			break
+		}
+		modes = append(modes, devMode)
+		iModeNum++
+	}
+	return modes, nil
+}
+
+// CurrentDevMode returns the DEVMODE the device is currently running
+func CurrentDevMode(deviceName string) (DEVMODE, error) {
+	return readDevMode(deviceName, ENUM_CURRENT_SETTINGS, "current")
+}
+
+// RegistryDevMode returns the DEVMODE stored in the registry for the device
+func RegistryDevMode(deviceName string) (DEVMODE, error) {
+	return readDevMode(deviceName, ENUM_REGISTRY_SETTINGS, "stored")
+}
+
+// readDevMode reads the current or the stored settings of a device
+func readDevMode(deviceName string, modeNum uint32, kind string) (DEVMODE, error) {
+	var devMode DEVMODE
+	devMode.DmSize = uint16(unsafe.Sizeof(devMode))
+	deviceNamePtr, _ := syscall.UTF16PtrFromString(deviceName)
+	if !EnumDisplaySettingsEx(deviceNamePtr, modeNum, &devMode, 0) {
+		return devMode, fmt.Errorf("could not read %s settings of %s", kind, deviceName)
+	}
+	return devMode, nil
+}
+
+// modeFromDevMode converts a DEVMODE into a backend-neutral Mode. Windows
+// reports the dimensions of rotated monitors swapped, Modes are kept unrotated.
+func modeFromDevMode(dm DEVMODE, orientation Orientation) Mode {
+	mode := Mode{
+		Width:        dm.DmPelsWidth,
+		Height:       dm.DmPelsHeight,
+		Frequency:    dm.DmDisplayFrequency,
+		BitsPerPixel: dm.DmBitsPerPel,
+		Interlaced:   dm.DmDisplayFlags&DM_INTERLACED != 0,
+	}
+	if orientation.SwapsAxes() {
+		mode.Width, mode.Height = mode.Height, mode.Width
+	}
+	return mode
+}
+
+// ListModes lists all available modes for a device
+func (win32Backend) ListModes(deviceName string) ([]Mode, error) {
+	current, err := CurrentDevMode(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	devModes, err := EnumDevModes(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	// The modes are enumerated in the orientation the device is currently in
+	modes := make([]Mode, 0, len(devModes))
+	for _, dm := range devModes {
+		modes = append(modes, modeFromDevMode(dm, Orientation(current.DmDisplayOrientation)))
+	}
+	return modes, nil
+}
+
+// CurrentMode returns the mode the device is currently running
+func (win32Backend) CurrentMode(deviceName string) (Mode, error) {
+	dm, err := CurrentDevMode(deviceName)
+	if err != nil {
+		return Mode{}, err
+	}
+	mode := modeFromDevMode(dm, Orientation(dm.DmDisplayOrientation))
+	// DmDisplayFrequency is truncated, the display path knows the exact rate
+	if rate, err := exactRefreshRate(deviceName); err == nil && rate.wholeHz(mode.Frequency) {
+		mode.Rate = rate
+	}
+	return mode, nil
+}
+
+// CurrentOrientation returns the rotation of the device
+func (win32Backend) CurrentOrientation(deviceName string) (Orientation, error) {
+	dm, err := CurrentDevMode(deviceName)
+	if err != nil {
+		return OrientationLandscape, err
+	}
+	return Orientation(dm.DmDisplayOrientation), nil
+}
+
+// CurrentPosition returns the desktop origin of the device
+func (win32Backend) CurrentPosition(deviceName string) (Position, error) {
+	dm, err := CurrentDevMode(deviceName)
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{X: dm.DmPosition.X, Y: dm.DmPosition.Y}, nil
+}
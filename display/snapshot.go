@@ -0,0 +1,273 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MAX_SNAPSHOTS is the number of snapshots kept in the snapshot file, older ones are dropped
+const MAX_SNAPSHOTS = 20
+
+// SNAPSHOT_FILE holds the snapshots in the state directory, newest first
+const SNAPSHOT_FILE = "snapshots.json"
+
+// MonitorState is the state of one active monitor in a snapshot
+type MonitorState struct {
+	MonitorID    string      `json:"monitor_id"`
+	FriendlyName string      `json:"friendly_name"`
+	DeviceName   string      `json:"device_name"`
+	Mode         Mode        `json:"mode"`
+	Orientation  Orientation `json:"orientation"`
+	Position     *Position   `json:"position,omitempty"` // nil when the backend cannot report positions
+}
+
+// Snapshot is the state of every active monitor at one point in time
+type Snapshot struct {
+	Time     time.Time      `json:"time"`
+	Reason   string         `json:"reason"` // What was about to change, e.g. "set 1920x1080 @ 60 Hz on \\.\DISPLAY1"
+	Monitors []MonitorState `json:"monitors"`
+}
+
+// snapshotFile holds the snapshots, newest first
+type snapshotFile struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// stateDir is where WRM keeps its own files, such as the snapshots, the default one when empty
+var stateDir string
+
+// SetStateDir sets the directory WRM keeps its snapshots and other state in
+func SetStateDir(dir string) {
+	stateDir = dir
+}
+
+// StateDir returns the state directory. By default it is "wrm" in the user's
+// config directory (%AppData% on Windows, ~/.config on Linux), so every WRM of
+// the user finds the same files whatever directory it runs in.
+func StateDir() string {
+	if stateDir != "" {
+		return stateDir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, "wrm")
+}
+
+// statePath returns the path of a file in the state directory, creating the directory when needed
+func statePath(name string) string {
+	dir := StateDir()
+	// A directory that cannot be created shows up as an error writing the file
+	os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, name)
+}
+
+// CaptureSnapshot reads the mode, rotation and position of every active monitor
+func CaptureSnapshot(reason string) (Snapshot, error) {
+	monitors, err := ListMonitors()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{Time: time.Now(), Reason: reason}
+	for _, mi := range monitors {
+		mode, err := CurrentBackend().CurrentMode(mi.DeviceName)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("error reading the current mode of %s: %v", mi.DeviceName, err)
+		}
+		orientation, err := CurrentOrientation(mi.DeviceName)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("error reading the orientation of %s: %v", mi.DeviceName, err)
+		}
+		state := MonitorState{
+			MonitorID:    mi.MonitorID,
+			FriendlyName: mi.FriendlyName,
+			DeviceName:   mi.DeviceName,
+			Mode:         mode,
+			Orientation:  orientation,
+		}
+		if pos, err := CurrentPosition(mi.DeviceName); err == nil {
+			state.Position = &pos
+		}
+		snapshot.Monitors = append(snapshot.Monitors, state)
+	}
+	return snapshot, nil
+}
+
+// SaveSnapshot captures the current state and stores it as the newest snapshot
+func SaveSnapshot(reason string) (Snapshot, error) {
+	snapshot, err := CaptureSnapshot(reason)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshots, err := LoadSnapshots()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshots = append([]Snapshot{snapshot}, snapshots...)
+	if len(snapshots) > MAX_SNAPSHOTS {
+		snapshots = snapshots[:MAX_SNAPSHOTS]
+	}
+	data, err := json.MarshalIndent(snapshotFile{Snapshots: snapshots}, "", "  ")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error marshaling snapshots: %v", err)
+	}
+	snapshotPath := statePath(SNAPSHOT_FILE)
+	if err := ioutil.WriteFile(snapshotPath, data, 0644); err != nil {
+		return Snapshot{}, fmt.Errorf("error writing snapshot file '%s': %v", snapshotPath, err)
+	}
+	return snapshot, nil
+}
+
+// LoadSnapshots returns the stored snapshots, newest first
+func LoadSnapshots() ([]Snapshot, error) {
+	snapshotPath := statePath(SNAPSHOT_FILE)
+	data, err := ioutil.ReadFile(snapshotPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read snapshot file '%s': %v", snapshotPath, err)
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid JSON in snapshot file '%s': %v", snapshotPath, err)
+	}
+	return file.Snapshots, nil
+}
+
+// PrintSnapshots lists the stored snapshots with their time and monitors, newest first
+func PrintSnapshots() error {
+	snapshots, err := LoadSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots stored.")
+		return nil
+	}
+	for i, s := range snapshots {
+		fmt.Printf("%d. %s  before %s\n", i+1, s.Time.Format("2006-01-02 15:04:05"), s.Reason)
+		for _, m := range s.Monitors {
+			fmt.Printf("     %s\n", m)
+		}
+	}
+	return nil
+}
+
+// String describes the monitor state, e.g. "27G2G5 (AOC-2702-DP-1): 1920x1080 @ 60 Hz, landscape at 0,0"
+func (m MonitorState) String() string {
+	s := fmt.Sprintf("%s (%s): %s, %s", m.FriendlyName, m.MonitorID, m.Mode, m.Orientation)
+	if m.Position != nil {
+		s += fmt.Sprintf(" at %d,%d", m.Position.X, m.Position.Y)
+	}
+	return s
+}
+
+// RestoreSnapshot switches the monitors back to the state of the snapshot after
+// asking the user. Monitors are matched by their stable ID, so the snapshot
+// survives device renumbering. With a backend that can switch monitors on and
+// off, monitors that were off at the time are switched off again and the others on.
+// Like any other change it is rolled back when a step fails and waits to be kept.
+func RestoreSnapshot(snapshot Snapshot) error {
+	toggles, err := snapshotToggles(snapshot)
+	if err != nil {
+		return err
+	}
+	return ApplyTransaction(Transaction{
+		Reason:  fmt.Sprintf("restoring the snapshot of %s", snapshot.Time.Format("2006-01-02 15:04:05")),
+		Toggles: toggles,
+		Resolve: func(pending bool) ([]ModeChange, error) {
+			return snapshotChanges(snapshot, pending)
+		},
+	})
+}
+
+// RevertToSnapshot is RestoreSnapshot without asking, for when the user may not see the screen
+func RevertToSnapshot(snapshot Snapshot) error {
+	toggles, err := snapshotToggles(snapshot)
+	if err != nil {
+		return err
+	}
+	if len(toggles) > 0 {
+		if err := CurrentBackend().(MonitorToggler).SetEnabled(toggles); err != nil {
+			return err
+		}
+	}
+	changes, err := snapshotChanges(snapshot, false)
+	if err != nil {
+		return err
+	}
+	return testAndApply(changes)
+}
+
+// snapshotToggles returns the monitors to switch on or off to match the
+// snapshot, none when the backend cannot switch monitors
+func snapshotToggles(snapshot Snapshot) (map[string]bool, error) {
+	if len(snapshot.Monitors) == 0 {
+		return nil, fmt.Errorf("the snapshot has no monitors")
+	}
+	toggles := make(map[string]bool)
+	if _, ok := CurrentBackend().(MonitorToggler); !ok {
+		return toggles, nil
+	}
+	wanted := make(map[string]bool)
+	for _, m := range snapshot.Monitors {
+		wanted[m.MonitorID] = true
+	}
+	all, err := ListAllMonitors()
+	if err != nil {
+		return nil, err
+	}
+	for _, mi := range all {
+		on := mi.Status != MONITOR_STATUS_DISABLED
+		if mi.MonitorID != "" && on != wanted[mi.MonitorID] {
+			toggles[mi.MonitorID] = wanted[mi.MonitorID]
+		}
+	}
+	return toggles, nil
+}
+
+// snapshotChanges returns the modes of the snapshot for the monitors that are
+// on. While monitors are still pending to be switched on the missing ones are
+// skipped silently.
+func snapshotChanges(snapshot Snapshot, pending bool) ([]ModeChange, error) {
+	monitors, err := ListMonitors()
+	if err != nil {
+		return nil, err
+	}
+	var changes []ModeChange
+	for _, m := range snapshot.Monitors {
+		mi, ok := findMonitorState(monitors, m)
+		if !ok {
+			if !pending {
+				fmt.Printf("Skipping %s, it is not connected.\n", m.FriendlyName)
+			}
+			continue
+		}
+		orientation := m.Orientation
+		change := ModeChange{DeviceName: mi.DeviceName, Mode: m.Mode, Orientation: &orientation, Position: m.Position}
+		// The primary monitor is the one at the origin of the desktop
+		if m.Position != nil && m.Position.X == 0 && m.Position.Y == 0 {
+			change.Primary = true
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 && !pending {
+		return nil, fmt.Errorf("none of the monitors of the snapshot are connected")
+	}
+	return changes, nil
+}
+
+// findMonitorState finds the monitor of a snapshot by its stable ID, or by its device name when it has none
+func findMonitorState(monitors []MonitorInfo, m MonitorState) (MonitorInfo, bool) {
+	for _, mi := range monitors {
+		if (m.MonitorID != "" && mi.MonitorID == m.MonitorID) || (m.MonitorID == "" && mi.DeviceName == m.DeviceName) {
+			return mi, true
+		}
+	}
+	return MonitorInfo{}, false
+}
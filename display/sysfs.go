@@ -0,0 +1,167 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SysfsBackend enumerates DRM/KMS connectors from sysfs. It works on headless
+// machines and in containers but is read-only: modes cannot be changed.
+type SysfsBackend struct {
+	Root string // Directory holding the card*-* connector entries, /sys/class/drm by default
+}
+
+// drmConnector is a connector directory such as /sys/class/drm/card0-HDMI-A-1
+type drmConnector struct {
+	Name    string // Directory name, e.g. "card0-HDMI-A-1"
+	Status  string // Content of the status file: connected, disconnected or unknown
+	Enabled string // Content of the enabled file: enabled or disabled
+	EDID    []byte
+	Modes   []Mode
+}
+
+var drmModeRe = regexp.MustCompile(`^(\d+)x(\d+)(i?)`)
+
+// NewSysfsBackend returns a backend reading /sys/class/drm
+func NewSysfsBackend() *SysfsBackend {
+	return &SysfsBackend{Root: "/sys/class/drm"}
+}
+
+func (b *SysfsBackend) Name() string {
+	return "sysfs"
+}
+
+// available reports whether Root exists
+func (b *SysfsBackend) available() bool {
+	info, err := os.Stat(b.Root)
+	return err == nil && info.IsDir()
+}
+
+// readSysfsFile returns the trimmed content of a sysfs attribute, or "" if it is missing
+func readSysfsFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readConnector reads every attribute of a connector directory
+func (b *SysfsBackend) readConnector(name string) (drmConnector, error) {
+	dir := filepath.Join(b.Root, name)
+	if _, err := os.Stat(dir); err != nil {
+		return drmConnector{}, fmt.Errorf("connector %s not found", name)
+	}
+	connector := drmConnector{
+		Name:    name,
+		Status:  readSysfsFile(filepath.Join(dir, "status")),
+		Enabled: readSysfsFile(filepath.Join(dir, "enabled")),
+	}
+	// The edid attribute is binary and empty when nothing is plugged in
+	if edid, err := os.ReadFile(filepath.Join(dir, "edid")); err == nil {
+		connector.EDID = edid
+	}
+	// The modes attribute only lists resolutions, e.g. "1920x1080" or "1920x1080i"
+	for _, line := range strings.Split(readSysfsFile(filepath.Join(dir, "modes")), "\n") {
+		m := drmModeRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		width, _ := strconv.ParseUint(m[1], 10, 32)
+		height, _ := strconv.ParseUint(m[2], 10, 32)
+		connector.Modes = append(connector.Modes, Mode{Width: uint32(width), Height: uint32(height), Interlaced: m[3] == "i"})
+	}
+	return connector, nil
+}
+
+// status returns the connection status, connected monitors that are switched off are disabled
+func (c drmConnector) status() string {
+	if c.Status == "connected" && c.Enabled == "disabled" {
+		return MONITOR_STATUS_DISABLED
+	}
+	return c.Status
+}
+
+// connectors returns every card*-* entry below Root, sorted by name
+func (b *SysfsBackend) connectors() ([]drmConnector, error) {
+	matches, err := filepath.Glob(filepath.Join(b.Root, "card*-*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var connectors []drmConnector
+	for _, match := range matches {
+		connector, err := b.readConnector(filepath.Base(match))
+		if err != nil {
+			return nil, err
+		}
+		connectors = append(connectors, connector)
+	}
+	return connectors, nil
+}
+
+// ListMonitors returns every connector together with its connection status
+func (b *SysfsBackend) ListMonitors() ([]MonitorInfo, error) {
+	connectors, err := b.connectors()
+	if err != nil {
+		return nil, err
+	}
+	if len(connectors) == 0 {
+		return nil, fmt.Errorf("no DRM connectors found in %s", b.Root)
+	}
+	var monitors []MonitorInfo
+	for i, connector := range connectors {
+		// Strip the "cardN-" prefix to get the connector name
+		connectorName := connector.Name[strings.Index(connector.Name, "-")+1:]
+		friendlyName := edidMonitorName(connector.EDID)
+		if friendlyName == "" {
+			friendlyName = connectorName
+		}
+		monitors = append(monitors, MonitorInfo{
+			Id:           uint32(i),
+			FriendlyName: friendlyName,
+			DeviceName:   connector.Name,
+			MonitorID:    edidMonitorID(connector.EDID, connectorName),
+			Status:       connector.status(),
+		})
+	}
+	return monitors, nil
+}
+
+// ListModes returns the resolutions advertised by the connector, sysfs does not expose refresh rates
+func (b *SysfsBackend) ListModes(deviceName string) ([]Mode, error) {
+	connector, err := b.readConnector(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Modes, nil
+}
+
+// ReadEDID returns the content of the connector's edid attribute
+func (b *SysfsBackend) ReadEDID(deviceName string) ([]byte, error) {
+	connector, err := b.readConnector(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(connector.EDID) == 0 {
+		return nil, fmt.Errorf("connector %s has no EDID", deviceName)
+	}
+	return connector.EDID, nil
+}
+
+func (b *SysfsBackend) CurrentMode(deviceName string) (Mode, error) {
+	return Mode{}, fmt.Errorf("the sysfs backend cannot report the current mode of %s", deviceName)
+}
+
+func (b *SysfsBackend) TestMode(change ModeChange) error {
+	return fmt.Errorf("the sysfs backend is read-only, changing modes is not supported")
+}
+
+func (b *SysfsBackend) ApplyModes(changes []ModeChange) error {
+	return fmt.Errorf("the sysfs backend is read-only, changing modes is not supported")
+}
@@ -0,0 +1,97 @@
+package display
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestSysfs builds a DRM tree with a monitor that is on, one that is
+// switched off and an empty connector, next to entries that are no connectors
+func newTestSysfs(t *testing.T) *SysfsBackend {
+	t.Helper()
+	root := t.TempDir()
+	writeTestConnector(t, root, "card0-HDMI-A-1", map[string][]byte{
+		"status":  []byte("connected\n"),
+		"enabled": []byte("enabled\n"),
+		"edid":    makeTestEDID("GSM", 0x5B7F, "LG TV"),
+		"modes":   []byte("1920x1080\n1920x1080\n1920x1080i\n1280x720\n"),
+	})
+	writeTestConnector(t, root, "card0-DP-1", map[string][]byte{
+		"status":  []byte("connected\n"),
+		"enabled": []byte("disabled\n"),
+		"edid":    makeTestEDID("AOC", 0x2702, "27G2G5"),
+		"modes":   []byte("2560x1440\n"),
+	})
+	writeTestConnector(t, root, "card0-DP-2", map[string][]byte{
+		"status":  []byte("disconnected\n"),
+		"enabled": []byte("disabled\n"),
+		"edid":    {},
+		"modes":   {},
+	})
+	writeTestConnector(t, root, "card0", map[string][]byte{"dev": []byte("226:0\n")})
+	return &SysfsBackend{Root: root}
+}
+
+func TestSysfsListMonitors(t *testing.T) {
+	b := newTestSysfs(t)
+	monitors, err := b.ListMonitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MonitorInfo{
+		{Id: 0, FriendlyName: "27G2G5", DeviceName: "card0-DP-1", MonitorID: "AOC-2702-DP-1", Status: MONITOR_STATUS_DISABLED},
+		{Id: 1, FriendlyName: "DP-2", DeviceName: "card0-DP-2", MonitorID: "DP-2", Status: "disconnected"},
+		{Id: 2, FriendlyName: "LG TV", DeviceName: "card0-HDMI-A-1", MonitorID: "GSM-5B7F-HDMI-A-1", Status: "connected"},
+	}
+	if !reflect.DeepEqual(monitors, want) {
+		t.Errorf("ListMonitors =\n%+v\nwant\n%+v", monitors, want)
+	}
+}
+
+func TestSysfsListModes(t *testing.T) {
+	b := newTestSysfs(t)
+	tests := []struct {
+		deviceName string
+		want       []Mode
+		wantErr    bool
+	}{
+		{
+			deviceName: "card0-HDMI-A-1",
+			want: []Mode{
+				{Width: 1920, Height: 1080},
+				{Width: 1920, Height: 1080},
+				{Width: 1920, Height: 1080, Interlaced: true},
+				{Width: 1280, Height: 720},
+			},
+		},
+		{deviceName: "card0-DP-2"},
+		{deviceName: "card0-VGA-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.deviceName, func(t *testing.T) {
+			modes, err := b.ListModes(tt.deviceName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListModes error = %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(modes, tt.want) {
+				t.Errorf("ListModes = %v, want %v", modes, tt.want)
+			}
+		})
+	}
+}
+
+func TestSysfsReadEDID(t *testing.T) {
+	b := newTestSysfs(t)
+	if raw, err := b.ReadEDID("card0-DP-1"); err != nil || len(raw) != 128 {
+		t.Errorf("ReadEDID = %d bytes, %v, want 128 bytes", len(raw), err)
+	}
+	if _, err := b.ReadEDID("card0-DP-2"); err == nil {
+		t.Errorf("ReadEDID of an empty connector succeeded")
+	}
+	if _, err := b.CurrentMode("card0-DP-1"); err == nil {
+		t.Errorf("CurrentMode succeeded on the read-only backend")
+	}
+	if !b.available() || (&SysfsBackend{Root: b.Root + "/missing"}).available() {
+		t.Errorf("available does not follow Root")
+	}
+}
@@ -0,0 +1,68 @@
+package display
+
+import "fmt"
+
+// TemporaryApplier is implemented by backends that can apply changes without
+// storing them as the default, so they are gone after a reboot, or 'wrm reset'
+// on backends that are also a ModeResetter
+type TemporaryApplier interface {
+	SetTemporary(temporary bool)
+}
+
+// ModeResetter is implemented by backends that can return to the stored modes after temporary changes
+type ModeResetter interface {
+	ResetModes() error
+}
+
+var temporary bool
+
+// CanReset reports whether the backend can return to the stored modes, so
+// 'wrm reset' undoes temporary changes. Elsewhere they last until the session ends.
+func CanReset() bool {
+	_, ok := CurrentBackend().(ModeResetter)
+	return ok
+}
+
+// Temporary reports whether changes are currently applied without storing them
+func Temporary() bool {
+	return temporary
+}
+
+// SetTemporary makes the following changes temporary, or stored again. It
+// fails when temporary changes are asked for and the backend always stores them.
+func SetTemporary(enabled bool) error {
+	applier, ok := CurrentBackend().(TemporaryApplier)
+	if !ok {
+		if enabled {
+			return fmt.Errorf("the %s backend always stores changes, temporary changes are not supported", CurrentBackend().Name())
+		}
+		return nil
+	}
+	applier.SetTemporary(enabled)
+	temporary = enabled
+	return nil
+}
+
+// ResetModes returns every monitor to the mode stored as its default, undoing
+// temporary changes. A snapshot is saved first so the reset can be restored.
+func ResetModes() error {
+	resetter, ok := CurrentBackend().(ModeResetter)
+	if !ok {
+		return fmt.Errorf("the %s backend has no stored modes to reset to", CurrentBackend().Name())
+	}
+	if dryRun {
+		fmt.Println("Would return every monitor to its stored mode.")
+		return ErrDryRun
+	}
+	if !confirm(Prompt{Question: "Return every monitor to its stored mode?"}) {
+		return ErrCancelled
+	}
+	if _, err := SaveSnapshot("reset"); err != nil {
+		return fmt.Errorf("error saving snapshot: %v", err)
+	}
+	if err := resetter.ResetModes(); err != nil {
+		return err
+	}
+	fmt.Println("Display settings reset to the stored modes.")
+	return nil
+}
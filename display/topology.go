@@ -0,0 +1,101 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology is one of the presets of the Windows projection menu (Win+P)
+type Topology string
+
+const (
+	TopologyExtend   Topology = "extend"   // Every monitor on, each showing its own part of the desktop
+	TopologyClone    Topology = "clone"    // Every monitor on, all showing the same picture
+	TopologyInternal Topology = "internal" // Only the built-in panel on
+	TopologyExternal Topology = "external" // Only the external monitors on
+)
+
+// ParseTopology parses a topology name
+func ParseTopology(value string) (Topology, error) {
+	switch t := Topology(strings.ToLower(strings.TrimSpace(value))); t {
+	case TopologyExtend, TopologyClone, TopologyInternal, TopologyExternal:
+		return t, nil
+	}
+	return "", fmt.Errorf("invalid topology '%s'. Use extend, clone, internal or external", value)
+}
+
+// TopologySetter is implemented by backends that apply the topology presets natively
+type TopologySetter interface {
+	SetTopology(topology Topology) error
+}
+
+// isInternalMonitor reports whether the monitor is a built-in laptop panel, going by its connector
+func isInternalMonitor(mi MonitorInfo) bool {
+	for _, connector := range []string{"eDP", "LVDS", "DSI", "INTERNAL"} {
+		if strings.Contains(mi.DeviceName, connector) || strings.Contains(mi.MonitorID, connector) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTopology switches to the topology after asking the user. Backends without
+// native support get extend, internal and external by switching monitors on and off.
+// Like a mode change it is rolled back when it fails and waits to be kept.
+func SetTopology(topology Topology) error {
+	return ApplyTransaction(Transaction{Reason: fmt.Sprintf("%s topology", topology), Topology: topology})
+}
+
+// checkTopology fails when the active backend cannot apply the topology
+func checkTopology(topology Topology) error {
+	if _, ok := CurrentBackend().(TopologySetter); ok {
+		return nil
+	}
+	_, err := topologyStates(topology)
+	return err
+}
+
+// applyTopology switches to the topology without asking
+func applyTopology(topology Topology) error {
+	backend := CurrentBackend()
+	if setter, ok := backend.(TopologySetter); ok {
+		return setter.SetTopology(topology)
+	}
+	states, err := topologyStates(topology)
+	if err != nil {
+		return err
+	}
+	return backend.(MonitorToggler).SetEnabled(states)
+}
+
+// topologyStates returns which monitors to switch on or off for the topology,
+// on backends without native support
+func topologyStates(topology Topology) (map[string]bool, error) {
+	backend := CurrentBackend()
+	toggler, ok := backend.(MonitorToggler)
+	if !ok || topology == TopologyClone {
+		return nil, fmt.Errorf("the %s backend does not support the %s topology", backend.Name(), topology)
+	}
+	monitors, err := toggler.ListAllMonitors()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]bool)
+	foundInternal := false
+	for _, mi := range monitors {
+		internal := isInternalMonitor(mi)
+		foundInternal = foundInternal || internal
+		switch topology {
+		case TopologyExtend:
+			states[mi.MonitorID] = true
+		case TopologyInternal:
+			states[mi.MonitorID] = internal
+		case TopologyExternal:
+			states[mi.MonitorID] = !internal
+		}
+	}
+	if !foundInternal && topology != TopologyExtend {
+		return nil, fmt.Errorf("no built-in monitor found")
+	}
+	return states, nil
+}
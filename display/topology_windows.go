@@ -0,0 +1,34 @@
+package display
+
+import "fmt"
+
+const (
+	SDC_TOPOLOGY_INTERNAL = 0x00000001
+	SDC_TOPOLOGY_CLONE    = 0x00000002
+	SDC_TOPOLOGY_EXTEND   = 0x00000004
+	SDC_TOPOLOGY_EXTERNAL = 0x00000008
+	// SDC_USE_DATABASE_CURRENT applies the configuration stored for the connected monitors
+	SDC_USE_DATABASE_CURRENT = SDC_TOPOLOGY_INTERNAL | SDC_TOPOLOGY_CLONE | SDC_TOPOLOGY_EXTEND | SDC_TOPOLOGY_EXTERNAL
+)
+
+// topologyFlags maps the presets to their SetDisplayConfig flags
+var topologyFlags = map[Topology]uint32{
+	TopologyInternal: SDC_TOPOLOGY_INTERNAL,
+	TopologyClone:    SDC_TOPOLOGY_CLONE,
+	TopologyExtend:   SDC_TOPOLOGY_EXTEND,
+	TopologyExternal: SDC_TOPOLOGY_EXTERNAL,
+}
+
+// SetTopology applies the preset the same way the Win+P menu does, Windows
+// restores the layout and modes it last used with that topology
+func (win32Backend) SetTopology(topology Topology) error {
+	flags, ok := topologyFlags[topology]
+	if !ok {
+		return fmt.Errorf("invalid topology '%s'", topology)
+	}
+	ret := SetDisplayConfig(0, nil, 0, nil, SDC_APPLY|flags)
+	if ret != ERROR_SUCCESS {
+		return fmt.Errorf("SetDisplayConfig failed with error %d", ret)
+	}
+	return nil
+}
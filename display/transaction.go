@@ -0,0 +1,128 @@
+package display
+
+import "fmt"
+
+// Transaction is a change made of several steps: the topology first, then the
+// monitors switched on or off, then the modes. It is confirmed once and either
+// applied as a whole or rolled back to the state from before it.
+type Transaction struct {
+	Reason   string          // Recorded with the snapshot, e.g. "config 'Gaming'"
+	Question string          // Asked before applying, "Apply these changes?" when empty
+	Topology Topology        // Topology to switch to, empty keeps it
+	Toggles  map[string]bool // Monitors to switch on (true) or off, keyed by MonitorID
+	// Resolve builds the mode changes. It runs before anything is applied, with
+	// pending set when the topology or toggles may still change which monitors
+	// are on and where they are, and again without once those steps are done.
+	Resolve func(pending bool) ([]ModeChange, error)
+}
+
+// pending reports whether the transaction switches monitors on or off before the modes
+func (t Transaction) pending() bool {
+	return t.Topology != "" || len(t.Toggles) > 0
+}
+
+// ApplyTransaction checks every step, asks the user once, applies the steps
+// and waits for the changes to be kept. When a step fails after the user
+// agreed, the state from before the transaction is restored.
+func ApplyTransaction(t Transaction) error {
+	if t.Topology != "" {
+		if err := checkTopology(t.Topology); err != nil {
+			return err
+		}
+	}
+	if len(t.Toggles) > 0 {
+		if _, ok := CurrentBackend().(MonitorToggler); !ok {
+			return fmt.Errorf("the %s backend cannot switch monitors on or off", CurrentBackend().Name())
+		}
+	}
+	// Check the modes before touching anything
+	changes, err := t.resolve(t.pending())
+	if err != nil {
+		return err
+	}
+	if !t.pending() && len(changes) == 0 {
+		return fmt.Errorf("no changes to apply")
+	}
+
+	if dryRun {
+		if t.Topology != "" {
+			fmt.Printf("Would switch to the %s topology.\n", t.Topology)
+		}
+		if len(t.Toggles) > 0 {
+			printToggles("The following monitors would be switched:", t.Toggles)
+		}
+		if len(changes) > 0 {
+			fmt.Println("The following changes would be applied:")
+			if err := PrintPlan(changes); err != nil {
+				return err
+			}
+		}
+		return ErrDryRun
+	}
+	question := t.Question
+	if question == "" {
+		question = "Apply these changes?"
+	}
+	if !confirm(Prompt{Question: question, Topology: t.Topology, Toggles: t.Toggles, Changes: changes}) {
+		return ErrCancelled
+	}
+
+	previous, err := SaveSnapshot(t.Reason)
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %v", err)
+	}
+	// The journal lets the next start roll back if WRM dies before the changes are kept
+	if err := BeginChange(previous); err != nil {
+		return err
+	}
+	if err := t.apply(changes); err != nil {
+		if restoreErr := RevertToSnapshot(previous); restoreErr != nil {
+			// The journal is left behind so the next start can still roll back
+			return fmt.Errorf("%v, restoring the previous settings failed too: %v", err, restoreErr)
+		}
+		EndChange()
+		return fmt.Errorf("%v, the previous settings were restored", err)
+	}
+	defer EndChange()
+	return KeepOrRevert(previous)
+}
+
+// resolve builds the mode changes and tests them with the backend
+func (t Transaction) resolve(pending bool) ([]ModeChange, error) {
+	if t.Resolve == nil {
+		return nil, nil
+	}
+	changes, err := t.Resolve(pending)
+	if err != nil {
+		return nil, err
+	}
+	if err := testChanges(changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// apply runs the steps of the transaction, the changes are the ones resolved beforehand
+func (t Transaction) apply(changes []ModeChange) error {
+	if t.Topology != "" {
+		if err := applyTopology(t.Topology); err != nil {
+			return err
+		}
+	}
+	if len(t.Toggles) > 0 {
+		if err := CurrentBackend().(MonitorToggler).SetEnabled(t.Toggles); err != nil {
+			return err
+		}
+	}
+	// Device names, modes and the desktop layout are only final now
+	if t.pending() {
+		var err error
+		if changes, err = t.resolve(false); err != nil {
+			return err
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return CurrentBackend().ApplyModes(changes)
+}
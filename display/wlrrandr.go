@@ -0,0 +1,382 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WlrRandrBackend drives wlroots compositors (Sway, Hyprland, ...) through
+// wlr-randr, which speaks the wlr-output-management protocol
+type WlrRandrBackend struct {
+	Command string // Path to the wlr-randr binary
+}
+
+// wlrHead is a single head (output) reported by wlr-randr
+type wlrHead struct {
+	Name        string
+	Description string
+	Make        string
+	Model       string
+	Serial      string
+	Enabled     bool
+	X           int32
+	Y           int32
+	Transform   string
+	Modes       []wlrMode
+}
+
+// wlrMode is a mode of a head, the refresh rate is kept in mHz as in the protocol
+type wlrMode struct {
+	Width     uint32
+	Height    uint32
+	Refresh   int32 // mHz
+	Preferred bool
+	Current   bool
+}
+
+var (
+	wlrHeadRe = regexp.MustCompile(`^(\S+)(?: "(.*)")?$`)
+	wlrModeRe = regexp.MustCompile(`^(\d+)x(\d+) px, ([\d.]+) Hz(?: \((.*)\))?$`)
+	wlrPosRe  = regexp.MustCompile(`^(-?\d+),(-?\d+)$`)
+)
+
+// NewWlrRandrBackend returns a backend using the wlr-randr found in PATH
+func NewWlrRandrBackend() *WlrRandrBackend {
+	return &WlrRandrBackend{Command: "wlr-randr"}
+}
+
+func (b *WlrRandrBackend) Name() string {
+	return "wlr-randr"
+}
+
+// parseWlrRandr parses the plain text output of wlr-randr
+func parseWlrRandr(data string) ([]wlrHead, error) {
+	var heads []wlrHead
+	var current *wlrHead
+	inModes := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Heads start at column 0, their properties are indented
+		if line[0] != ' ' && line[0] != '\t' {
+			m := wlrHeadRe.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid wlr-randr head line: %q", line)
+			}
+			heads = append(heads, wlrHead{Name: m[1], Description: m[2]})
+			current = &heads[len(heads)-1]
+			inModes = false
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("unexpected wlr-randr line before any head: %q", line)
+		}
+
+		text := strings.TrimSpace(line)
+		if inModes {
+			if m := wlrModeRe.FindStringSubmatch(text); m != nil {
+				width, _ := strconv.ParseUint(m[1], 10, 32)
+				height, _ := strconv.ParseUint(m[2], 10, 32)
+				rate, _ := strconv.ParseFloat(m[3], 64)
+				current.Modes = append(current.Modes, wlrMode{
+					Width:     uint32(width),
+					Height:    uint32(height),
+					Refresh:   int32(math.Round(rate * 1000)),
+					Preferred: strings.Contains(m[4], "preferred"),
+					Current:   strings.Contains(m[4], "current"),
+				})
+				continue
+			}
+			inModes = false
+		}
+
+		key, value, found := strings.Cut(text, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Make":
+			current.Make = value
+		case "Model":
+			current.Model = value
+		case "Serial":
+			current.Serial = value
+		case "Enabled":
+			current.Enabled = value == "yes"
+		case "Modes":
+			inModes = true
+		case "Position":
+			if m := wlrPosRe.FindStringSubmatch(value); m != nil {
+				x, _ := strconv.ParseInt(m[1], 10, 32)
+				y, _ := strconv.ParseInt(m[2], 10, 32)
+				current.X, current.Y = int32(x), int32(y)
+			}
+		case "Transform":
+			current.Transform = value
+		}
+	}
+	return heads, nil
+}
+
+// query runs wlr-randr and returns the parsed heads
+func (b *WlrRandrBackend) query() ([]wlrHead, error) {
+	data, err := runCommand(b.Command)
+	if err != nil {
+		return nil, err
+	}
+	return parseWlrRandr(string(data))
+}
+
+// findHead returns the head with the given name
+func (b *WlrRandrBackend) findHead(name string) (*wlrHead, error) {
+	heads, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	for i := range heads {
+		if heads[i].Name == name {
+			return &heads[i], nil
+		}
+	}
+	return nil, fmt.Errorf("head %s not found", name)
+}
+
+// toMode converts a wlr-randr mode into a backend-neutral Mode
+func (m wlrMode) toMode() Mode {
+	return Mode{
+		Width:     m.Width,
+		Height:    m.Height,
+		Frequency: uint32(math.Round(float64(m.Refresh) / 1000)),
+		Rate:      RefreshRate{Numerator: uint32(m.Refresh), Denominator: 1000},
+	}
+}
+
+// findMode picks the head mode closest to the requested one
+func (h *wlrHead) findMode(mode Mode) (*wlrMode, error) {
+	var best *wlrMode
+	target := int32(mode.Frequency) * 1000
+	for i := range h.Modes {
+		m := &h.Modes[i]
+		if m.toMode() != mode {
+			continue
+		}
+		if best == nil || abs32(m.Refresh-target) < abs32(best.Refresh-target) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("mode %s not available on %s", mode, h.Name)
+	}
+	return best, nil
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// monitorInfo converts the head into a MonitorInfo
+func (h *wlrHead) monitorInfo(id int) MonitorInfo {
+	friendlyName := h.Model
+	if friendlyName == "" {
+		friendlyName = h.Name
+	}
+	mi := MonitorInfo{
+		Id:           uint32(id),
+		FriendlyName: friendlyName,
+		DeviceName:   h.Name,
+		MonitorID:    makeMonitorID(h.Make, h.Model, h.Name),
+	}
+	if !h.Enabled {
+		mi.Status = MONITOR_STATUS_DISABLED
+	}
+	return mi
+}
+
+// ListMonitors returns every enabled head
+func (b *WlrRandrBackend) ListMonitors() ([]MonitorInfo, error) {
+	heads, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors []MonitorInfo
+	for i, head := range heads {
+		if head.Enabled {
+			monitors = append(monitors, head.monitorInfo(i))
+		}
+	}
+	return monitors, nil
+}
+
+// ListAllMonitors returns the enabled heads followed by the disabled ones
+func (b *WlrRandrBackend) ListAllMonitors() ([]MonitorInfo, error) {
+	heads, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors, disabled []MonitorInfo
+	for i, head := range heads {
+		if head.Enabled {
+			monitors = append(monitors, head.monitorInfo(i))
+		} else {
+			disabled = append(disabled, head.monitorInfo(i))
+		}
+	}
+	return append(monitors, disabled...), nil
+}
+
+// SetEnabled switches heads on or off in a single wlr-randr invocation
+func (b *WlrRandrBackend) SetEnabled(states map[string]bool) error {
+	monitors, err := b.ListAllMonitors()
+	if err != nil {
+		return err
+	}
+	var args []string
+	for _, mi := range monitors {
+		on, ok := states[mi.MonitorID]
+		if !ok {
+			continue
+		}
+		if on {
+			args = append(args, "--output", mi.DeviceName, "--on")
+		} else {
+			args = append(args, "--output", mi.DeviceName, "--off")
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("none of the monitors to switch is connected")
+	}
+	_, err = runCommand(b.Command, args...)
+	return err
+}
+
+func (b *WlrRandrBackend) ListModes(deviceName string) ([]Mode, error) {
+	head, err := b.findHead(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	modes := make([]Mode, 0, len(head.Modes))
+	for _, m := range head.Modes {
+		modes = append(modes, m.toMode())
+	}
+	return modes, nil
+}
+
+func (b *WlrRandrBackend) CurrentMode(deviceName string) (Mode, error) {
+	head, err := b.findHead(deviceName)
+	if err != nil {
+		return Mode{}, err
+	}
+	for _, m := range head.Modes {
+		if m.Current {
+			return m.toMode(), nil
+		}
+	}
+	return Mode{}, fmt.Errorf("head %s has no active mode", deviceName)
+}
+
+// CurrentPosition returns the position of the head in the compositor layout
+func (b *WlrRandrBackend) CurrentPosition(deviceName string) (Position, error) {
+	head, err := b.findHead(deviceName)
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{X: head.X, Y: head.Y}, nil
+}
+
+// CurrentOrientation returns the rotation part of the head transform
+func (b *WlrRandrBackend) CurrentOrientation(deviceName string) (Orientation, error) {
+	head, err := b.findHead(deviceName)
+	if err != nil {
+		return OrientationLandscape, err
+	}
+	// Flipped transforms are written "flipped-90", the mirroring is not an orientation
+	transform := strings.TrimPrefix(strings.TrimPrefix(head.Transform, "flipped"), "-")
+	if transform == "" {
+		return OrientationLandscape, nil
+	}
+	return ParseOrientation(transform)
+}
+
+// wlrTransform formats an orientation the way wlr-randr --transform expects it
+func wlrTransform(o Orientation) string {
+	if o == OrientationLandscape {
+		return "normal"
+	}
+	return strconv.FormatUint(uint64(o.Degrees()), 10)
+}
+
+// modeArgs returns the wlr-randr arguments switching the head to the mode
+func (b *WlrRandrBackend) modeArgs(deviceName string, mode Mode) ([]string, error) {
+	head, err := b.findHead(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	m, err := head.findMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--output", head.Name, "--mode", formatWlrMode(*m)}, nil
+}
+
+// formatWlrMode formats a mode the way wlr-randr --mode expects it, e.g. "1920x1080@59.940000Hz"
+func formatWlrMode(m wlrMode) string {
+	return fmt.Sprintf("%dx%d@%.6fHz", m.Width, m.Height, float64(m.Refresh)/1000)
+}
+
+// changeArgs builds the wlr-randr arguments for one head, transform and position included
+func (b *WlrRandrBackend) changeArgs(c ModeChange) ([]string, error) {
+	args, err := b.modeArgs(c.DeviceName, c.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if c.Orientation != nil {
+		args = append(args, "--transform", wlrTransform(*c.Orientation))
+	}
+	if c.Position != nil {
+		args = append(args, "--pos", fmt.Sprintf("%d,%d", c.Position.X, c.Position.Y))
+	}
+	return args, nil
+}
+
+// TestMode asks the compositor to test the configuration with --dryrun
+func (b *WlrRandrBackend) TestMode(change ModeChange) error {
+	args, err := b.changeArgs(change)
+	if err != nil {
+		return err
+	}
+	_, err = runCommand(b.Command, append([]string{"--dryrun"}, args...)...)
+	return err
+}
+
+// PrimaryAtOrigin marks wlroots as placing the primary monitor at 0,0, the
+// output layout accepts negative positions around it
+func (b *WlrRandrBackend) PrimaryAtOrigin() {}
+
+// SetTemporary does nothing, wlr-randr changes only last as long as the compositor anyway
+func (b *WlrRandrBackend) SetTemporary(temporary bool) {}
+
+// ApplyModes changes every head with a single wlr-randr invocation, which the
+// compositor applies as one configuration. wlroots has no primary output, a
+// primary monitor only ends up at 0,0.
+func (b *WlrRandrBackend) ApplyModes(changes []ModeChange) error {
+	var args []string
+	for _, c := range changes {
+		headArgs, err := b.changeArgs(c)
+		if err != nil {
+			return err
+		}
+		args = append(args, headArgs...)
+	}
+	_, err := runCommand(b.Command, args...)
+	return err
+}
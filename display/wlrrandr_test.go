@@ -0,0 +1,84 @@
+package display
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWlrRandr(t *testing.T) {
+	// A laptop panel below a monitor rotated to portrait on the right and a
+	// monitor that is switched off
+	heads, err := parseWlrRandr(string(readTestdata(t, "wlr_randr.txt")))
+	if err != nil {
+		t.Fatalf("parseWlrRandr failed: %v", err)
+	}
+	tests := []struct {
+		name string
+		want wlrHead
+	}{
+		{
+			name: "eDP-1",
+			want: wlrHead{
+				Name: "eDP-1", Description: "BOE 0x0A1C (eDP-1)",
+				Make: "BOE", Model: "0x0A1C", Serial: "(null)",
+				Enabled: true, Y: 840, Transform: "normal",
+				Modes: []wlrMode{
+					{Width: 1920, Height: 1080, Refresh: 59999, Preferred: true, Current: true},
+					{Width: 1920, Height: 1080, Refresh: 47998},
+				},
+			},
+		},
+		{
+			name: "DP-1",
+			want: wlrHead{
+				Name: "DP-1", Description: "AOC 27G2G5 1A2B3C4D5E6F (DP-1)",
+				Make: "AOC", Model: "27G2G5", Serial: "1A2B3C4D5E6F",
+				Enabled: true, X: 1920, Transform: "90",
+				Modes: []wlrMode{
+					{Width: 1920, Height: 1080, Refresh: 144004, Preferred: true, Current: true},
+					{Width: 1920, Height: 1080, Refresh: 119996},
+					{Width: 1920, Height: 1080, Refresh: 60000},
+					{Width: 1920, Height: 1080, Refresh: 59940},
+				},
+			},
+		},
+		{
+			name: "HDMI-A-1",
+			want: wlrHead{
+				Name: "HDMI-A-1", Description: "Ancor Communications Inc VG248 G8LMQS012345 (HDMI-A-1)",
+				Make: "Ancor Communications Inc", Model: "VG248", Serial: "G8LMQS012345",
+				Modes: []wlrMode{
+					{Width: 1920, Height: 1080, Refresh: 60000, Preferred: true},
+					{Width: 1280, Height: 1024, Refresh: 75025},
+				},
+			},
+		},
+	}
+	if len(heads) != len(tests) {
+		t.Fatalf("got %d heads, want %d", len(heads), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(heads[i], tt.want) {
+				t.Errorf("head = %+v\nwant %+v", heads[i], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWlrRandrErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "property before any head", data: "  Enabled: yes\n"},
+		{name: "invalid head line", data: "DP-1 AOC 27G2G5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseWlrRandr(tt.data); err == nil {
+				t.Errorf("parseWlrRandr accepted %q", tt.data)
+			}
+		})
+	}
+}
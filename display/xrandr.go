@@ -0,0 +1,428 @@
+package display
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// XrandrBackend drives X11 displays through the xrandr command line tool
+type XrandrBackend struct {
+	Command string // Path to the xrandr binary
+}
+
+// xrandrOutput is a single output block of `xrandr --query --verbose`
+type xrandrOutput struct {
+	Name      string
+	Connected bool
+	Primary   bool
+	Active    bool // The output has a CRTC and a geometry assigned
+	X         int32
+	Y         int32
+	Width     uint32
+	Height    uint32
+	Rotation  string
+	EDID      []byte
+	Modes     []xrandrMode
+}
+
+// xrandrMode is a single mode line of an output
+type xrandrMode struct {
+	Name       string // e.g. "1920x1080" or "1920x1080i"
+	ID         string // e.g. "0x48"
+	Width      uint32
+	Height     uint32
+	Rate       float64 // Vertical refresh in Hz, e.g. 59.94
+	Current    bool
+	Preferred  bool
+	Interlaced bool
+}
+
+var (
+	xrandrGeometryRe = regexp.MustCompile(`^(\d+)x(\d+)\+(-?\d+)\+(-?\d+)$`)
+	xrandrModeRe     = regexp.MustCompile(`^\s+(\S+) \((0x[0-9a-fA-F]+)\)\s+[\d.]+MHz(.*)$`)
+	xrandrHLineRe    = regexp.MustCompile(`^\s+h: width\s+(\d+)`)
+	xrandrVLineRe    = regexp.MustCompile(`^\s+v: height\s+(\d+).*clock\s+([\d.]+)Hz`)
+)
+
+// xrandrRotations maps orientations to the values of --rotate, which rotates counterclockwise
+var xrandrRotations = map[Orientation]string{
+	OrientationLandscape:        "normal",
+	OrientationPortrait:         "left",
+	OrientationLandscapeFlipped: "inverted",
+	OrientationPortraitFlipped:  "right",
+}
+
+// NewXrandrBackend returns a backend using the xrandr found in PATH
+func NewXrandrBackend() *XrandrBackend {
+	return &XrandrBackend{Command: "xrandr"}
+}
+
+func (b *XrandrBackend) Name() string {
+	return "xrandr"
+}
+
+// parseXrandrVerbose parses the output of `xrandr --query --verbose`
+func parseXrandrVerbose(data string) ([]xrandrOutput, error) {
+	var outputs []xrandrOutput
+	var current *xrandrOutput
+	var mode *xrandrMode
+	readingEDID := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "Screen ") {
+			continue
+		}
+
+		// Output header lines are the only ones without indentation
+		if line[0] != ' ' && line[0] != '\t' {
+			output, err := parseXrandrOutputHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, output)
+			current = &outputs[len(outputs)-1]
+			mode = nil
+			readingEDID = false
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("unexpected xrandr line before any output: %q", line)
+		}
+
+		// Properties are indented with a tab, their values with two
+		if strings.HasPrefix(line, "\t\t") {
+			if readingEDID {
+				blob, err := hex.DecodeString(strings.TrimSpace(line))
+				if err != nil {
+					return nil, fmt.Errorf("invalid EDID for %s: %v", current.Name, err)
+				}
+				current.EDID = append(current.EDID, blob...)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			readingEDID = strings.HasPrefix(strings.TrimSpace(line), "EDID:")
+			continue
+		}
+		readingEDID = false
+
+		if m := xrandrModeRe.FindStringSubmatch(line); m != nil {
+			flags := m[3]
+			current.Modes = append(current.Modes, xrandrMode{
+				Name:       m[1],
+				ID:         m[2],
+				Current:    strings.Contains(flags, "*current"),
+				Preferred:  strings.Contains(flags, "+preferred"),
+				Interlaced: strings.Contains(flags, "Interlace"),
+			})
+			mode = &current.Modes[len(current.Modes)-1]
+			continue
+		}
+		if mode == nil {
+			continue
+		}
+		if m := xrandrHLineRe.FindStringSubmatch(line); m != nil {
+			width, _ := strconv.ParseUint(m[1], 10, 32)
+			mode.Width = uint32(width)
+			continue
+		}
+		if m := xrandrVLineRe.FindStringSubmatch(line); m != nil {
+			height, _ := strconv.ParseUint(m[1], 10, 32)
+			rate, _ := strconv.ParseFloat(m[2], 64)
+			mode.Height = uint32(height)
+			mode.Rate = rate
+		}
+	}
+	return outputs, nil
+}
+
+// parseXrandrOutputHeader parses a line such as
+// "HDMI-1 connected primary 1920x1080+0+0 (0x48) normal (normal left inverted right x axis y axis) 531mm x 299mm"
+func parseXrandrOutputHeader(line string) (xrandrOutput, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return xrandrOutput{}, fmt.Errorf("invalid xrandr output line: %q", line)
+	}
+	output := xrandrOutput{
+		Name:      fields[0],
+		Connected: fields[1] == "connected",
+	}
+	for i := 2; i < len(fields); i++ {
+		field := fields[i]
+		if strings.HasPrefix(field, "(normal") {
+			// The list of supported rotations ends the interesting part
+			break
+		}
+		switch {
+		case field == "primary":
+			output.Primary = true
+		case xrandrGeometryRe.MatchString(field):
+			m := xrandrGeometryRe.FindStringSubmatch(field)
+			width, _ := strconv.ParseUint(m[1], 10, 32)
+			height, _ := strconv.ParseUint(m[2], 10, 32)
+			x, _ := strconv.ParseInt(m[3], 10, 32)
+			y, _ := strconv.ParseInt(m[4], 10, 32)
+			output.Active = true
+			output.Width, output.Height = uint32(width), uint32(height)
+			output.X, output.Y = int32(x), int32(y)
+		case field == "normal" || field == "left" || field == "inverted" || field == "right":
+			output.Rotation = field
+		}
+	}
+	return output, nil
+}
+
+// query runs xrandr and returns the parsed outputs
+func (b *XrandrBackend) query() ([]xrandrOutput, error) {
+	data, err := runCommand(b.Command, "--query", "--verbose")
+	if err != nil {
+		return nil, err
+	}
+	return parseXrandrVerbose(string(data))
+}
+
+// findOutput returns the output with the given name
+func (b *XrandrBackend) findOutput(name string) (*xrandrOutput, error) {
+	outputs, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	for i := range outputs {
+		if outputs[i].Name == name {
+			return &outputs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("output %s not found", name)
+}
+
+// toMode converts an xrandr mode into a backend-neutral Mode
+func (m xrandrMode) toMode() Mode {
+	return Mode{
+		Width:      m.Width,
+		Height:     m.Height,
+		Frequency:  uint32(math.Round(m.Rate)),
+		Rate:       rateFromHz(m.Rate),
+		Interlaced: m.Interlaced,
+	}
+}
+
+// findMode picks the xrandr mode closest to the requested one
+func (o *xrandrOutput) findMode(mode Mode) (*xrandrMode, error) {
+	var best *xrandrMode
+	for i := range o.Modes {
+		m := &o.Modes[i]
+		if m.toMode() != mode {
+			continue
+		}
+		if best == nil || math.Abs(m.Rate-float64(mode.Frequency)) < math.Abs(best.Rate-float64(mode.Frequency)) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("mode %s not available on %s", mode, o.Name)
+	}
+	return best, nil
+}
+
+// monitorInfo converts the output into a MonitorInfo
+func (o *xrandrOutput) monitorInfo(id int) MonitorInfo {
+	friendlyName := edidMonitorName(o.EDID)
+	if friendlyName == "" {
+		friendlyName = o.Name
+	}
+	mi := MonitorInfo{
+		Id:           uint32(id),
+		FriendlyName: friendlyName,
+		DeviceName:   o.Name,
+		MonitorID:    edidMonitorID(o.EDID, o.Name),
+	}
+	if !o.Active {
+		mi.Status = MONITOR_STATUS_DISABLED
+	}
+	return mi
+}
+
+// ListMonitors returns every connected and active output
+func (b *XrandrBackend) ListMonitors() ([]MonitorInfo, error) {
+	outputs, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors []MonitorInfo
+	for i, output := range outputs {
+		if !output.Connected || !output.Active {
+			continue
+		}
+		monitors = append(monitors, output.monitorInfo(i))
+	}
+	return monitors, nil
+}
+
+// ListAllMonitors returns the active outputs followed by the connected ones that are off
+func (b *XrandrBackend) ListAllMonitors() ([]MonitorInfo, error) {
+	outputs, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	var monitors, disabled []MonitorInfo
+	for i, output := range outputs {
+		if !output.Connected {
+			continue
+		}
+		if output.Active {
+			monitors = append(monitors, output.monitorInfo(i))
+		} else {
+			disabled = append(disabled, output.monitorInfo(i))
+		}
+	}
+	return append(monitors, disabled...), nil
+}
+
+// SetEnabled switches outputs on with their preferred mode or off, in a single xrandr invocation
+func (b *XrandrBackend) SetEnabled(states map[string]bool) error {
+	monitors, err := b.ListAllMonitors()
+	if err != nil {
+		return err
+	}
+	var args []string
+	for _, mi := range monitors {
+		on, ok := states[mi.MonitorID]
+		if !ok {
+			continue
+		}
+		if on {
+			args = append(args, "--output", mi.DeviceName, "--auto")
+		} else {
+			args = append(args, "--output", mi.DeviceName, "--off")
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("none of the monitors to switch is connected")
+	}
+	_, err = runCommand(b.Command, args...)
+	return err
+}
+
+func (b *XrandrBackend) ListModes(deviceName string) ([]Mode, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	modes := make([]Mode, 0, len(output.Modes))
+	for _, m := range output.Modes {
+		modes = append(modes, m.toMode())
+	}
+	return modes, nil
+}
+
+func (b *XrandrBackend) CurrentMode(deviceName string) (Mode, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return Mode{}, err
+	}
+	for _, m := range output.Modes {
+		if m.Current {
+			return m.toMode(), nil
+		}
+	}
+	return Mode{}, fmt.Errorf("output %s has no active mode", deviceName)
+}
+
+// CurrentPosition returns the origin of the output on the X screen
+func (b *XrandrBackend) CurrentPosition(deviceName string) (Position, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{X: output.X, Y: output.Y}, nil
+}
+
+// CurrentOrientation returns the rotation of the output
+func (b *XrandrBackend) CurrentOrientation(deviceName string) (Orientation, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return OrientationLandscape, err
+	}
+	for o, rotation := range xrandrRotations {
+		if rotation == output.Rotation {
+			return o, nil
+		}
+	}
+	return OrientationLandscape, nil
+}
+
+// ReadEDID returns the EDID property of the output
+func (b *XrandrBackend) ReadEDID(deviceName string) ([]byte, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.EDID) == 0 {
+		return nil, fmt.Errorf("output %s has no EDID", deviceName)
+	}
+	return output.EDID, nil
+}
+
+// modeArgs returns the xrandr arguments switching the output to the mode. The
+// mode is passed by its XID, names are shared by reduced blanking variants and
+// by 59.94 and 60 Hz modes, and the rate alone cannot tell them apart.
+func (b *XrandrBackend) modeArgs(deviceName string, mode Mode) ([]string, error) {
+	output, err := b.findOutput(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	m, err := output.findMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--output", output.Name, "--mode", m.ID}, nil
+}
+
+// changeArgs builds the xrandr arguments for one output, rotation and position included
+func (b *XrandrBackend) changeArgs(c ModeChange) ([]string, error) {
+	args, err := b.modeArgs(c.DeviceName, c.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if c.Orientation != nil {
+		args = append(args, "--rotate", xrandrRotations[*c.Orientation])
+	}
+	if c.Position != nil {
+		args = append(args, "--pos", fmt.Sprintf("%dx%d", c.Position.X, c.Position.Y))
+	}
+	if c.Primary {
+		args = append(args, "--primary")
+	}
+	return args, nil
+}
+
+// TestMode validates the change with `xrandr --dryrun`
+func (b *XrandrBackend) TestMode(change ModeChange) error {
+	args, err := b.changeArgs(change)
+	if err != nil {
+		return err
+	}
+	_, err = runCommand(b.Command, append([]string{"--dryrun"}, args...)...)
+	return err
+}
+
+// SetTemporary does nothing, xrandr changes only last as long as the X session anyway
+func (b *XrandrBackend) SetTemporary(temporary bool) {}
+
+// ApplyModes changes every output with a single xrandr invocation
+func (b *XrandrBackend) ApplyModes(changes []ModeChange) error {
+	var args []string
+	for _, c := range changes {
+		outputArgs, err := b.changeArgs(c)
+		if err != nil {
+			return err
+		}
+		args = append(args, outputArgs...)
+	}
+	_, err := runCommand(b.Command, args...)
+	return err
+}
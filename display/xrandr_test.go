@@ -0,0 +1,185 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readTestdata returns the content of a file in testdata
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseXrandrVerbose(t *testing.T) {
+	// A laptop panel below a monitor rotated to portrait on the right, a
+	// connected but inactive monitor and an empty connector
+	outputs, err := parseXrandrVerbose(string(readTestdata(t, "xrandr_verbose.txt")))
+	if err != nil {
+		t.Fatalf("parseXrandrVerbose failed: %v", err)
+	}
+	tests := []struct {
+		name string
+		edid string // Capture in ../edid/testdata the EDID property holds
+		want xrandrOutput
+	}{
+		{
+			name: "eDP-1",
+			edid: "boe_laptop_panel.bin",
+			want: xrandrOutput{
+				Name: "eDP-1", Connected: true, Primary: true, Active: true,
+				Y: 840, Width: 1920, Height: 1080, Rotation: "normal",
+				Modes: []xrandrMode{
+					{Name: "1920x1080", ID: "0x47", Width: 1920, Height: 1080, Rate: 60, Current: true, Preferred: true},
+					{Name: "1920x1080", ID: "0x48", Width: 1920, Height: 1080, Rate: 48},
+					{Name: "1280x720", ID: "0x49", Width: 1280, Height: 720, Rate: 59.86},
+				},
+			},
+		},
+		{
+			name: "DP-1",
+			edid: "aoc_27g2g5.bin",
+			want: xrandrOutput{
+				Name: "DP-1", Connected: true, Active: true,
+				X: 1920, Width: 1080, Height: 1920, Rotation: "left",
+				Modes: []xrandrMode{
+					{Name: "1920x1080", ID: "0x4a", Width: 1920, Height: 1080, Rate: 144, Current: true, Preferred: true},
+					{Name: "1920x1080", ID: "0x4b", Width: 1920, Height: 1080, Rate: 60},
+					{Name: "1920x1080", ID: "0x4c", Width: 1920, Height: 1080, Rate: 59.94},
+					{Name: "1920x1080i", ID: "0x4d", Width: 1920, Height: 1080, Rate: 60, Interlaced: true},
+				},
+			},
+		},
+		{
+			name: "HDMI-1",
+			want: xrandrOutput{
+				Name: "HDMI-1", Connected: true,
+				Modes: []xrandrMode{
+					{Name: "1280x1024", ID: "0x4e", Width: 1280, Height: 1024, Rate: 75.02, Preferred: true},
+					{Name: "1280x1024", ID: "0x4f", Width: 1280, Height: 1024, Rate: 60.02},
+				},
+			},
+		},
+		{
+			name: "DP-2",
+			want: xrandrOutput{Name: "DP-2"},
+		},
+	}
+	if len(outputs) != len(tests) {
+		t.Fatalf("got %d outputs, want %d", len(outputs), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outputs[i]
+			if tt.edid != "" {
+				edid, err := os.ReadFile(filepath.Join("..", "edid", "testdata", tt.edid))
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.want.EDID = edid
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseXrandrVerboseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "mode before any output", data: "  1920x1080 (0x47) 138.650MHz *current\n"},
+		{name: "truncated output line", data: "HDMI-1\n"},
+		{name: "invalid EDID", data: "HDMI-1 connected\n\tEDID: \n\t\t00ffzz\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseXrandrVerbose(tt.data); err == nil {
+				t.Errorf("parseXrandrVerbose accepted %q", tt.data)
+			}
+		})
+	}
+}
+
+// fakeTool writes a script standing in for a display tool: it prints the
+// capture from testdata and logs its arguments, one invocation per line
+func fakeTool(t *testing.T, capture string) (command string, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	command = filepath.Join(dir, "tool")
+	log = filepath.Join(dir, "args.log")
+	data, err := filepath.Abs(filepath.Join("testdata", capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> '%s'\ncat '%s'\n", log, data)
+	if err := os.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return command, log
+}
+
+// lastInvocation returns the arguments the fake tool was last called with
+func lastInvocation(t *testing.T, log string) string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestXrandrApplyModes(t *testing.T) {
+	mode := func(hz float64, interlaced bool) Mode {
+		return Mode{Width: 1920, Height: 1080, Frequency: uint32(math.Round(hz)), Rate: rateFromHz(hz), Interlaced: interlaced}
+	}
+	portrait := OrientationPortrait
+	tests := []struct {
+		name    string
+		change  ModeChange
+		want    string
+		wantErr bool
+	}{
+		// 60 and 59.94 Hz share the name 1920x1080, only the XID tells them apart
+		{name: "60 Hz", change: ModeChange{DeviceName: "DP-1", Mode: mode(60, false)}, want: "--output DP-1 --mode 0x4b"},
+		{name: "59.94 Hz", change: ModeChange{DeviceName: "DP-1", Mode: mode(59.94, false)}, want: "--output DP-1 --mode 0x4c"},
+		{name: "interlaced", change: ModeChange{DeviceName: "DP-1", Mode: mode(60, true)}, want: "--output DP-1 --mode 0x4d"},
+		{
+			name:   "rotated, moved and primary",
+			change: ModeChange{DeviceName: "DP-1", Mode: mode(144, false), Orientation: &portrait, Position: &Position{X: 1920}, Primary: true},
+			want:   "--output DP-1 --mode 0x4a --rotate left --pos 1920x0 --primary",
+		},
+		{name: "unknown mode", change: ModeChange{DeviceName: "DP-1", Mode: mode(75, false)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, log := fakeTool(t, "xrandr_verbose.txt")
+			b := &XrandrBackend{Command: command}
+			err := b.ApplyModes([]ModeChange{tt.change})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ApplyModes accepted %v", tt.change.Mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyModes failed: %v", err)
+			}
+			if got := lastInvocation(t, log); got != tt.want {
+				t.Errorf("xrandr %s, want xrandr %s", got, tt.want)
+			}
+		})
+	}
+}
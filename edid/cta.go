@@ -0,0 +1,358 @@
+package edid
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// ExtensionCTA is the tag of a CTA-861 extension block
+	ExtensionCTA = 0x02
+
+	ctaVideoBlock    = 2
+	ctaVendorBlock   = 3
+	ctaExtendedBlock = 7
+
+	ctaExtColorimetry = 0x05
+	ctaExtHDRStatic   = 0x06
+	ctaExtYCbCr420    = 0x0E
+	ctaExtHFSCDB      = 0x79
+
+	ouiHDMI       = 0x000C03
+	ouiHDMIForum  = 0xC45DD8
+	hdmiTMDSUnits = 5 // Max TMDS clock and character rate are given in 5 MHz units
+)
+
+// CTAExtension is a decoded CTA-861 extension block
+type CTAExtension struct {
+	Revision        uint8
+	Underscan       bool
+	BasicAudio      bool
+	YCbCr444        bool
+	YCbCr422        bool
+	DetailedTimings []DetailedTiming
+	DataBlocks
+}
+
+// DataBlocks holds what WRM decodes from a CTA data block collection. The
+// same blocks can also be embedded in DisplayID sections.
+type DataBlocks struct {
+	VideoFormats []VideoFormat
+	HDMI         *HDMIInfo
+	HDR          *HDRStaticMetadata
+	Colorimetry  []string
+}
+
+// VideoFormat is a CTA-861 short video descriptor resolved through the VIC table
+type VideoFormat struct {
+	VIC          uint8
+	Width        uint16
+	Height       uint16
+	Refresh      float64
+	Interlaced   bool
+	Native       bool
+	YCbCr420Only bool // Listed in the YCbCr 4:2:0 video data block
+}
+
+// HDMIInfo collects the link capabilities from the HDMI VSDB and HF-VSDB/HF-SCDB
+type HDMIInfo struct {
+	MaxTMDSClock    uint16 // MHz, from the HDMI 1.x VSDB, 0 when not given
+	MaxTMDSCharRate uint16 // MHz, from the HDMI Forum block, 0 when not given
+	MaxFRLRate      uint8  // Max_FRL_Rate field of the HDMI Forum block, 0 when FRL is not supported
+}
+
+// HDRStaticMetadata is the HDR static metadata data block
+type HDRStaticMetadata struct {
+	SDR                  bool    // Traditional gamma, SDR luminance range
+	TraditionalHDR       bool    // Traditional gamma, HDR luminance range
+	PQ                   bool    // SMPTE ST 2084
+	HLG                  bool    // Hybrid Log-Gamma
+	MaxLuminance         float64 // cd/m², 0 when not given
+	MaxFrameAvgLuminance float64 // cd/m², 0 when not given
+	MinLuminance         float64 // cd/m², 0 when not given
+}
+
+// frlRates maps the Max_FRL_Rate field to lanes and per-lane rate in Gbit/s
+var frlRates = map[uint8]struct {
+	Lanes uint8
+	Gbps  uint8
+}{
+	1: {3, 3},
+	2: {3, 6},
+	3: {4, 6},
+	4: {4, 8},
+	5: {4, 10},
+	6: {4, 12},
+}
+
+var colorimetryNames = []string{
+	"xvYCC601", "xvYCC709", "sYCC601", "opYCC601", "opRGB", "BT2020cYCC", "BT2020YCC", "BT2020RGB",
+}
+
+// parseCTA decodes a 128 byte CTA-861 extension block
+func parseCTA(block []byte) (*CTAExtension, error) {
+	if block[0] != ExtensionCTA {
+		return nil, fmt.Errorf("not a CTA-861 extension block")
+	}
+	ext := &CTAExtension{Revision: block[1]}
+	dtdOffset := int(block[2])
+	if block[1] >= 2 {
+		ext.Underscan = block[3]&0x80 != 0
+		ext.BasicAudio = block[3]&0x40 != 0
+		ext.YCbCr444 = block[3]&0x20 != 0
+		ext.YCbCr422 = block[3]&0x10 != 0
+	}
+	// Revision 1 blocks have no data block collection
+	if block[1] >= 3 && dtdOffset > 4 && dtdOffset <= BlockSize-1 {
+		if err := ext.DataBlocks.parse(block[4:dtdOffset]); err != nil {
+			return nil, err
+		}
+	}
+	if dtdOffset >= 4 {
+		for offset := dtdOffset; offset+18 <= BlockSize-1; offset += 18 {
+			t, ok := ParseDetailedTiming(block[offset : offset+18])
+			if !ok {
+				break
+			}
+			ext.DetailedTimings = append(ext.DetailedTimings, t)
+		}
+	}
+	return ext, nil
+}
+
+// parse decodes a CTA data block collection
+func (db *DataBlocks) parse(data []byte) error {
+	var ycbcr420 []byte
+	for i := 0; i < len(data); {
+		tag := data[i] >> 5
+		length := int(data[i] & 0x1F)
+		if i+1+length > len(data) {
+			return fmt.Errorf("CTA data block overflows the collection")
+		}
+		payload := data[i+1 : i+1+length]
+		i += 1 + length
+
+		switch tag {
+		case ctaVideoBlock:
+			for _, svd := range payload {
+				db.VideoFormats = append(db.VideoFormats, decodeSVD(svd))
+			}
+		case ctaVendorBlock:
+			db.parseVendorBlock(payload)
+		case ctaExtendedBlock:
+			if len(payload) == 0 {
+				continue
+			}
+			switch payload[0] {
+			case ctaExtColorimetry:
+				db.parseColorimetry(payload[1:])
+			case ctaExtHDRStatic:
+				db.HDR = parseHDRStatic(payload[1:])
+			case ctaExtYCbCr420:
+				ycbcr420 = append(ycbcr420, payload[1:]...)
+			case ctaExtHFSCDB:
+				// Same layout as the HF-VSDB, with the OUI replaced by the tag and 2 reserved bytes
+				db.parseHDMIForum(payload)
+			}
+		}
+	}
+	for _, svd := range ycbcr420 {
+		format := decodeSVD(svd)
+		format.YCbCr420Only = true
+		db.VideoFormats = append(db.VideoFormats, format)
+	}
+	return nil
+}
+
+// decodeSVD resolves a short video descriptor through the VIC table
+func decodeSVD(svd byte) VideoFormat {
+	vic := svd
+	native := false
+	// Since CTA-861-F only VICs 1-64 carry the native flag in bit 7
+	if svd >= 129 && svd <= 192 {
+		vic = svd & 0x7F
+		native = true
+	}
+	format := VideoFormat{VIC: vic, Native: native}
+	if t, ok := vicTable[vic]; ok {
+		format.Width = t.Width
+		format.Height = t.Height
+		format.Refresh = t.Refresh
+		format.Interlaced = t.Interlaced
+	}
+	return format
+}
+
+// parseVendorBlock decodes the HDMI VSDB and the HDMI Forum VSDB
+func (db *DataBlocks) parseVendorBlock(payload []byte) {
+	if len(payload) < 3 {
+		return
+	}
+	oui := uint32(payload[0]) | uint32(payload[1])<<8 | uint32(payload[2])<<16
+	switch oui {
+	case ouiHDMI:
+		// OUI, physical address, flags, then Max_TMDS_Clock
+		if len(payload) >= 7 {
+			db.hdmi().MaxTMDSClock = uint16(payload[6]) * hdmiTMDSUnits
+		}
+	case ouiHDMIForum:
+		db.parseHDMIForum(payload)
+	}
+}
+
+// parseHDMIForum decodes an HF-VSDB or HF-SCDB payload, starting at the OUI/tag bytes
+func (db *DataBlocks) parseHDMIForum(payload []byte) {
+	if len(payload) < 5 {
+		return
+	}
+	hdmi := db.hdmi()
+	hdmi.MaxTMDSCharRate = uint16(payload[4]) * hdmiTMDSUnits
+	if len(payload) >= 7 {
+		hdmi.MaxFRLRate = payload[6] >> 4
+	}
+}
+
+// hdmi returns the HDMI info, allocating it on first use
+func (db *DataBlocks) hdmi() *HDMIInfo {
+	if db.HDMI == nil {
+		db.HDMI = &HDMIInfo{}
+	}
+	return db.HDMI
+}
+
+// parseColorimetry decodes the colorimetry data block
+func (db *DataBlocks) parseColorimetry(payload []byte) {
+	if len(payload) < 1 {
+		return
+	}
+	for bit, name := range colorimetryNames {
+		if payload[0]&(1<<bit) != 0 {
+			db.Colorimetry = append(db.Colorimetry, name)
+		}
+	}
+	if len(payload) >= 2 && payload[1]&0x80 != 0 {
+		db.Colorimetry = append(db.Colorimetry, "DCI-P3")
+	}
+}
+
+// parseHDRStatic decodes the HDR static metadata data block
+func parseHDRStatic(payload []byte) *HDRStaticMetadata {
+	if len(payload) < 2 {
+		return nil
+	}
+	hdr := &HDRStaticMetadata{
+		SDR:            payload[0]&0x01 != 0,
+		TraditionalHDR: payload[0]&0x02 != 0,
+		PQ:             payload[0]&0x04 != 0,
+		HLG:            payload[0]&0x08 != 0,
+	}
+	// Luminance values are coded as 50*2^(CV/32), the minimum is relative to the maximum
+	if len(payload) >= 3 && payload[2] != 0 {
+		hdr.MaxLuminance = 50 * math.Pow(2, float64(payload[2])/32)
+	}
+	if len(payload) >= 4 && payload[3] != 0 {
+		hdr.MaxFrameAvgLuminance = 50 * math.Pow(2, float64(payload[3])/32)
+	}
+	if len(payload) >= 5 && payload[4] != 0 {
+		hdr.MinLuminance = hdr.MaxLuminance * math.Pow(float64(payload[4])/255, 2) / 100
+	}
+	return hdr
+}
+
+// HDR reports whether the sink advertises a HDR transfer function
+func (h *HDRStaticMetadata) HDR() bool {
+	return h != nil && (h.PQ || h.HLG || h.TraditionalHDR)
+}
+
+// FRLBandwidth returns the number of lanes and the per-lane rate in Gbit/s of the max FRL rate
+func (h *HDMIInfo) FRLBandwidth() (uint8, uint8) {
+	rate, ok := frlRates[h.MaxFRLRate]
+	if !ok {
+		return 0, 0
+	}
+	return rate.Lanes, rate.Gbps
+}
+
+// String formats the video format, e.g. "VIC 16: 1920x1080p @ 60 Hz (native)"
+func (f VideoFormat) String() string {
+	if f.Width == 0 {
+		return fmt.Sprintf("VIC %d: unknown", f.VIC)
+	}
+	scan := "p"
+	if f.Interlaced {
+		scan = "i"
+	}
+	s := fmt.Sprintf("VIC %d: %dx%d%s @ %g Hz", f.VIC, f.Width, f.Height, scan, f.Refresh)
+	if f.Native {
+		s += " (native)"
+	}
+	if f.YCbCr420Only {
+		s += " (YCbCr 4:2:0)"
+	}
+	return s
+}
+
+// vicTiming is an entry of the CTA-861 VIC table
+type vicTiming struct {
+	Width      uint16
+	Height     uint16
+	Refresh    float64
+	Interlaced bool
+}
+
+// vicTable maps CTA-861 VICs to their active format. Aspect ratio variants
+// of the same format share the same entry content.
+var vicTable = map[uint8]vicTiming{
+	1: {640, 480, 60, false}, 2: {720, 480, 60, false}, 3: {720, 480, 60, false},
+	4: {1280, 720, 60, false}, 5: {1920, 1080, 60, true}, 6: {1440, 480, 60, true},
+	7: {1440, 480, 60, true}, 8: {1440, 240, 60, false}, 9: {1440, 240, 60, false},
+	10: {2880, 480, 60, true}, 11: {2880, 480, 60, true}, 12: {2880, 240, 60, false},
+	13: {2880, 240, 60, false}, 14: {1440, 480, 60, false}, 15: {1440, 480, 60, false},
+	16: {1920, 1080, 60, false}, 17: {720, 576, 50, false}, 18: {720, 576, 50, false},
+	19: {1280, 720, 50, false}, 20: {1920, 1080, 50, true}, 21: {1440, 576, 50, true},
+	22: {1440, 576, 50, true}, 23: {1440, 288, 50, false}, 24: {1440, 288, 50, false},
+	25: {2880, 576, 50, true}, 26: {2880, 576, 50, true}, 27: {2880, 288, 50, false},
+	28: {2880, 288, 50, false}, 29: {1440, 576, 50, false}, 30: {1440, 576, 50, false},
+	31: {1920, 1080, 50, false}, 32: {1920, 1080, 24, false}, 33: {1920, 1080, 25, false},
+	34: {1920, 1080, 30, false}, 35: {2880, 480, 60, false}, 36: {2880, 480, 60, false},
+	37: {2880, 576, 50, false}, 38: {2880, 576, 50, false}, 39: {1920, 1080, 50, true},
+	40: {1920, 1080, 100, true}, 41: {1280, 720, 100, false}, 42: {720, 576, 100, false},
+	43: {720, 576, 100, false}, 44: {1440, 576, 100, true}, 45: {1440, 576, 100, true},
+	46: {1920, 1080, 120, true}, 47: {1280, 720, 120, false}, 48: {720, 480, 120, false},
+	49: {720, 480, 120, false}, 50: {1440, 480, 120, true}, 51: {1440, 480, 120, true},
+	52: {720, 576, 200, false}, 53: {720, 576, 200, false}, 54: {1440, 576, 200, true},
+	55: {1440, 576, 200, true}, 56: {720, 480, 240, false}, 57: {720, 480, 240, false},
+	58: {1440, 480, 240, true}, 59: {1440, 480, 240, true}, 60: {1280, 720, 24, false},
+	61: {1280, 720, 25, false}, 62: {1280, 720, 30, false}, 63: {1920, 1080, 120, false},
+	64: {1920, 1080, 100, false}, 65: {1280, 720, 24, false}, 66: {1280, 720, 25, false},
+	67: {1280, 720, 30, false}, 68: {1280, 720, 50, false}, 69: {1280, 720, 60, false},
+	70: {1280, 720, 100, false}, 71: {1280, 720, 120, false}, 72: {1920, 1080, 24, false},
+	73: {1920, 1080, 25, false}, 74: {1920, 1080, 30, false}, 75: {1920, 1080, 50, false},
+	76: {1920, 1080, 60, false}, 77: {1920, 1080, 100, false}, 78: {1920, 1080, 120, false},
+	79: {1680, 720, 24, false}, 80: {1680, 720, 25, false}, 81: {1680, 720, 30, false},
+	82: {1680, 720, 50, false}, 83: {1680, 720, 60, false}, 84: {1680, 720, 100, false},
+	85: {1680, 720, 120, false}, 86: {2560, 1080, 24, false}, 87: {2560, 1080, 25, false},
+	88: {2560, 1080, 30, false}, 89: {2560, 1080, 50, false}, 90: {2560, 1080, 60, false},
+	91: {2560, 1080, 100, false}, 92: {2560, 1080, 120, false}, 93: {3840, 2160, 24, false},
+	94: {3840, 2160, 25, false}, 95: {3840, 2160, 30, false}, 96: {3840, 2160, 50, false},
+	97: {3840, 2160, 60, false}, 98: {4096, 2160, 24, false}, 99: {4096, 2160, 25, false},
+	100: {4096, 2160, 30, false}, 101: {4096, 2160, 50, false}, 102: {4096, 2160, 60, false},
+	103: {3840, 2160, 24, false}, 104: {3840, 2160, 25, false}, 105: {3840, 2160, 30, false},
+	106: {3840, 2160, 50, false}, 107: {3840, 2160, 60, false}, 108: {1280, 720, 48, false},
+	109: {1280, 720, 48, false}, 110: {1680, 720, 48, false}, 111: {1920, 1080, 48, false},
+	112: {1920, 1080, 48, false}, 113: {2560, 1080, 48, false}, 114: {3840, 2160, 48, false},
+	115: {4096, 2160, 48, false}, 116: {3840, 2160, 48, false}, 117: {3840, 2160, 100, false},
+	118: {3840, 2160, 120, false}, 119: {3840, 2160, 100, false}, 120: {3840, 2160, 120, false},
+	121: {5120, 2160, 24, false}, 122: {5120, 2160, 25, false}, 123: {5120, 2160, 30, false},
+	124: {5120, 2160, 48, false}, 125: {5120, 2160, 50, false}, 126: {5120, 2160, 60, false},
+	127: {5120, 2160, 100, false}, 193: {5120, 2160, 120, false}, 194: {7680, 4320, 24, false},
+	195: {7680, 4320, 25, false}, 196: {7680, 4320, 30, false}, 197: {7680, 4320, 48, false},
+	198: {7680, 4320, 50, false}, 199: {7680, 4320, 60, false}, 200: {7680, 4320, 100, false},
+	201: {7680, 4320, 120, false}, 202: {7680, 4320, 24, false}, 203: {7680, 4320, 25, false},
+	204: {7680, 4320, 30, false}, 205: {7680, 4320, 48, false}, 206: {7680, 4320, 50, false},
+	207: {7680, 4320, 60, false}, 208: {7680, 4320, 100, false}, 209: {7680, 4320, 120, false},
+	210: {10240, 4320, 24, false}, 211: {10240, 4320, 25, false}, 212: {10240, 4320, 30, false},
+	213: {10240, 4320, 48, false}, 214: {10240, 4320, 50, false}, 215: {10240, 4320, 60, false},
+	216: {10240, 4320, 100, false}, 217: {10240, 4320, 120, false}, 218: {4096, 2160, 100, false},
+	219: {4096, 2160, 120, false},
+}
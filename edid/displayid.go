@@ -0,0 +1,87 @@
+package edid
+
+import "fmt"
+
+const (
+	// ExtensionDisplayID is the tag of a DisplayID extension block
+	ExtensionDisplayID = 0x70
+
+	displayIDTypeITiming   = 0x03 // DisplayID 1.x Type I detailed timing
+	displayIDTypeVIITiming = 0x22 // DisplayID 2.0 Type VII detailed timing
+	displayIDCTABlocks     = 0x81 // CTA-861 data blocks embedded in DisplayID
+)
+
+// DisplayIDExtension is a decoded DisplayID section carried in an EDID extension block
+type DisplayIDExtension struct {
+	Version         uint8 // e.g. 0x12 for 1.2, 0x20 for 2.0
+	ProductType     uint8 // Product type (1.x) or primary use case (2.0)
+	DetailedTimings []DetailedTiming
+	DataBlocks      // CTA data blocks embedded in the section
+}
+
+// parseDisplayID decodes a 128 byte DisplayID extension block
+func parseDisplayID(block []byte) (*DisplayIDExtension, error) {
+	if block[0] != ExtensionDisplayID {
+		return nil, fmt.Errorf("not a DisplayID extension block")
+	}
+	ext := &DisplayIDExtension{
+		Version:     block[1],
+		ProductType: block[3],
+	}
+	// The section starts after the extension tag, its payload after the 4 byte section header
+	end := 5 + int(block[2])
+	if end > BlockSize-1 {
+		return nil, fmt.Errorf("DisplayID section overflows the extension block")
+	}
+	for i := 5; i+3 <= end; {
+		tag := block[i]
+		length := int(block[i+2])
+		if tag == 0 && length == 0 {
+			// Padding
+			break
+		}
+		if i+3+length > end {
+			return nil, fmt.Errorf("DisplayID data block overflows the section")
+		}
+		payload := block[i+3 : i+3+length]
+		i += 3 + length
+
+		switch tag {
+		case displayIDTypeITiming:
+			// Type I counts the pixel clock in 10 kHz units, Type VII in 1 kHz units
+			ext.parseTimings(payload, 10)
+		case displayIDTypeVIITiming:
+			ext.parseTimings(payload, 1)
+		case displayIDCTABlocks:
+			if err := ext.DataBlocks.parse(payload); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ext, nil
+}
+
+// parseTimings decodes a list of 20 byte Type I / Type VII timing descriptors
+func (ext *DisplayIDExtension) parseTimings(payload []byte, clockUnitKHz uint32) {
+	for offset := 0; offset+20 <= len(payload); offset += 20 {
+		d := payload[offset : offset+20]
+		le16 := func(i int) uint16 {
+			return uint16(d[i]) | uint16(d[i+1])<<8
+		}
+		// All fields are stored minus one, sync offsets carry the polarity in bit 15
+		t := DetailedTiming{
+			PixelClock:  ((uint32(d[0]) | uint32(d[1])<<8 | uint32(d[2])<<16) + 1) * clockUnitKHz,
+			Interlaced:  d[3]&0x10 != 0,
+			HActive:     le16(4) + 1,
+			HBlank:      le16(6) + 1,
+			HSyncOffset: (le16(8) & 0x7FFF) + 1,
+			HSyncWidth:  le16(10) + 1,
+			VActive:     le16(12) + 1,
+			VBlank:      le16(14) + 1,
+			VSyncOffset: (le16(16) & 0x7FFF) + 1,
+			VSyncWidth:  le16(18) + 1,
+		}
+		t.PixelClockHz = uint64(t.PixelClock) * 1000
+		ext.DetailedTimings = append(ext.DetailedTimings, t)
+	}
+}
@@ -0,0 +1,540 @@
+package edid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	// BlockSize is the size of the EDID base block and of every extension block
+	BlockSize = 128
+
+	descriptorSerial      = 0xFF
+	descriptorText        = 0xFE
+	descriptorRangeLimits = 0xFD
+	descriptorName        = 0xFC
+)
+
+var header = []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+
+// EDID is a decoded EDID 1.x base block
+type EDID struct {
+	Manufacturer    string // PNP id, e.g. "AOC"
+	ProductCode     uint16
+	SerialNumber    uint32
+	Week            uint8 // 0 when unknown, 0xFF when Year is a model year
+	Year            int
+	Version         uint8
+	Revision        uint8
+	Digital         bool
+	BitDepth        uint8  // Bits per color, 0 when undefined or analog
+	Interface       string // Digital interface, e.g. "DisplayPort"
+	WidthCm         uint8  // Physical size, both 0 when undefined
+	HeightCm        uint8
+	DetailedTimings []DetailedTiming
+	StandardTimings []StandardTiming
+	RangeLimits     *RangeLimits
+	MonitorName     string
+	MonitorSerial   string
+	Texts           []string // Unspecified text descriptors
+	Extensions      uint8    // Number of extension blocks following the base block
+	CTA             []*CTAExtension
+	DisplayID       []*DisplayIDExtension
+	BadExtensions   []ExtensionError // Extension blocks that could not be decoded
+	Raw             []byte
+}
+
+// ExtensionError records an extension block that is missing, has a bad checksum or cannot be decoded
+type ExtensionError struct {
+	Block int  // Index of the block, 1 is the first extension block
+	Tag   byte // First byte of the block, e.g. 0x02 for CTA-861, 0 when the block is missing
+	Err   error
+}
+
+func (e ExtensionError) Error() string {
+	return fmt.Sprintf("extension block %d: %v", e.Block, e.Err)
+}
+
+// DetailedTiming is an 18 byte detailed timing descriptor
+type DetailedTiming struct {
+	PixelClock   uint32 // kHz
+	HActive      uint16
+	HBlank       uint16
+	VActive      uint16
+	VBlank       uint16
+	HSyncOffset  uint16
+	HSyncWidth   uint16
+	VSyncOffset  uint16
+	VSyncWidth   uint16
+	WidthMm      uint16
+	HeightMm     uint16
+	Interlaced   bool
+	PixelClockHz uint64
+}
+
+// StandardTiming is a 2 byte standard timing entry
+type StandardTiming struct {
+	Width   uint16
+	Height  uint16
+	Refresh uint8
+}
+
+// RangeLimits is the display range limits descriptor (tag 0xFD)
+type RangeLimits struct {
+	MinVRate      uint16 // Hz
+	MaxVRate      uint16 // Hz
+	MinHRate      uint16 // kHz
+	MaxHRate      uint16 // kHz
+	MaxPixelClock uint16 // MHz, 0 when not given
+}
+
+var interfaces = map[byte]string{
+	0x1: "DVI",
+	0x2: "HDMIa",
+	0x3: "HDMIb",
+	0x4: "MDDI",
+	0x5: "DisplayPort",
+}
+
+// Parse decodes an EDID base block followed by its CTA-861 and DisplayID
+// extension blocks. Extension blocks of other types are kept in Raw but not
+// decoded. Extension blocks that are missing, have a bad checksum or cannot be
+// decoded are recorded in BadExtensions, only a bad base block is an error.
+func Parse(data []byte) (*EDID, error) {
+	if len(data) < BlockSize {
+		return nil, fmt.Errorf("EDID too short: %d bytes", len(data))
+	}
+	for i, b := range header {
+		if data[i] != b {
+			return nil, fmt.Errorf("invalid EDID header")
+		}
+	}
+	if !validChecksum(data[:BlockSize]) {
+		return nil, fmt.Errorf("invalid EDID checksum")
+	}
+
+	e := &EDID{
+		Manufacturer: DecodePNPID(binary.BigEndian.Uint16(data[8:10])),
+		ProductCode:  binary.LittleEndian.Uint16(data[10:12]),
+		SerialNumber: binary.LittleEndian.Uint32(data[12:16]),
+		Week:         data[16],
+		Year:         int(data[17]) + 1990,
+		Version:      data[18],
+		Revision:     data[19],
+		Digital:      data[20]&0x80 != 0,
+		WidthCm:      data[21],
+		HeightCm:     data[22],
+		Extensions:   data[126],
+		Raw:          data,
+	}
+	if e.Digital && (e.Version > 1 || e.Revision >= 4) {
+		if depth := (data[20] >> 4) & 0x07; depth > 0 && depth < 7 {
+			e.BitDepth = 4 + depth*2
+		}
+		e.Interface = interfaces[data[20]&0x0F]
+	}
+
+	// Standard timings, 0x0101 marks an unused slot
+	for i := 38; i < 54; i += 2 {
+		if t, ok := decodeStandardTiming(data[i], data[i+1], e.Revision); ok {
+			e.StandardTimings = append(e.StandardTimings, t)
+		}
+	}
+
+	// Four 18 byte descriptors, either detailed timings or display descriptors
+	for offset := 54; offset <= 108; offset += 18 {
+		e.parseDescriptor(data[offset : offset+18])
+	}
+
+	for i := 1; i <= int(e.Extensions); i++ {
+		if (i+1)*BlockSize > len(data) {
+			// The blocks after it are missing as well
+			e.BadExtensions = append(e.BadExtensions, ExtensionError{Block: i, Err: fmt.Errorf("missing, the EDID ends after %d bytes", len(data))})
+			break
+		}
+		block := data[i*BlockSize : (i+1)*BlockSize]
+		if !validChecksum(block) {
+			e.BadExtensions = append(e.BadExtensions, ExtensionError{Block: i, Tag: block[0], Err: fmt.Errorf("invalid checksum")})
+			continue
+		}
+		switch block[0] {
+		case ExtensionCTA:
+			ext, err := parseCTA(block)
+			if err != nil {
+				e.BadExtensions = append(e.BadExtensions, ExtensionError{Block: i, Tag: block[0], Err: err})
+				continue
+			}
+			e.CTA = append(e.CTA, ext)
+		case ExtensionDisplayID:
+			ext, err := parseDisplayID(block)
+			if err != nil {
+				e.BadExtensions = append(e.BadExtensions, ExtensionError{Block: i, Tag: block[0], Err: err})
+				continue
+			}
+			e.DisplayID = append(e.DisplayID, ext)
+		}
+	}
+	return e, nil
+}
+
+// validChecksum reports whether the bytes of a block sum to 0 modulo 256
+func validChecksum(block []byte) bool {
+	var sum byte
+	for _, b := range block {
+		sum += b
+	}
+	return sum == 0
+}
+
+// DecodePNPID converts the packed manufacturer id into its three letter form
+func DecodePNPID(id uint16) string {
+	return string([]byte{
+		byte((id>>10)&0x1F) + 'A' - 1,
+		byte((id>>5)&0x1F) + 'A' - 1,
+		byte(id&0x1F) + 'A' - 1,
+	})
+}
+
+// decodeStandardTiming decodes a standard timing, ok is false for unused entries
+func decodeStandardTiming(b1, b2 byte, revision uint8) (StandardTiming, bool) {
+	if (b1 == 0x01 && b2 == 0x01) || b1 == 0x00 {
+		return StandardTiming{}, false
+	}
+	width := (uint16(b1) + 31) * 8
+	var height uint16
+	switch b2 >> 6 {
+	case 0:
+		// Before EDID 1.3 this meant 1:1
+		if revision < 3 {
+			height = width
+		} else {
+			height = width * 10 / 16
+		}
+	case 1:
+		height = width * 3 / 4
+	case 2:
+		height = width * 4 / 5
+	case 3:
+		height = width * 9 / 16
+	}
+	return StandardTiming{Width: width, Height: height, Refresh: b2&0x3F + 60}, true
+}
+
+// parseDescriptor decodes one of the four 18 byte descriptors of the base block
+func (e *EDID) parseDescriptor(d []byte) {
+	if t, ok := ParseDetailedTiming(d); ok {
+		e.DetailedTimings = append(e.DetailedTimings, t)
+		return
+	}
+	switch d[3] {
+	case descriptorName:
+		e.MonitorName = descriptorString(d)
+	case descriptorSerial:
+		e.MonitorSerial = descriptorString(d)
+	case descriptorText:
+		e.Texts = append(e.Texts, descriptorString(d))
+	case descriptorRangeLimits:
+		e.RangeLimits = parseRangeLimits(d)
+	}
+}
+
+// ParseDetailedTiming decodes an 18 byte detailed timing descriptor, ok is
+// false when the descriptor is a display descriptor instead
+func ParseDetailedTiming(d []byte) (DetailedTiming, bool) {
+	if len(d) < 18 {
+		return DetailedTiming{}, false
+	}
+	clock := binary.LittleEndian.Uint16(d[0:2])
+	if clock == 0 {
+		return DetailedTiming{}, false
+	}
+	t := DetailedTiming{
+		PixelClock:  uint32(clock) * 10,
+		HActive:     uint16(d[2]) | uint16(d[4]&0xF0)<<4,
+		HBlank:      uint16(d[3]) | uint16(d[4]&0x0F)<<8,
+		VActive:     uint16(d[5]) | uint16(d[7]&0xF0)<<4,
+		VBlank:      uint16(d[6]) | uint16(d[7]&0x0F)<<8,
+		HSyncOffset: uint16(d[8]) | uint16(d[11]&0xC0)<<2,
+		HSyncWidth:  uint16(d[9]) | uint16(d[11]&0x30)<<4,
+		VSyncOffset: uint16(d[10]>>4) | uint16(d[11]&0x0C)<<2,
+		VSyncWidth:  uint16(d[10]&0x0F) | uint16(d[11]&0x03)<<4,
+		WidthMm:     uint16(d[12]) | uint16(d[14]&0xF0)<<4,
+		HeightMm:    uint16(d[13]) | uint16(d[14]&0x0F)<<8,
+		Interlaced:  d[17]&0x80 != 0,
+	}
+	t.PixelClockHz = uint64(t.PixelClock) * 1000
+	return t, true
+}
+
+// Refresh returns the vertical refresh rate of the timing in Hz
+func (t DetailedTiming) Refresh() float64 {
+	total := float64(t.HActive+t.HBlank) * float64(t.VActive+t.VBlank)
+	if total == 0 {
+		return 0
+	}
+	// For interlaced timings the vertical values describe a field, so this is the field rate
+	return float64(t.PixelClockHz) / total
+}
+
+// descriptorString returns the text of a display descriptor, terminated by a line feed
+func descriptorString(d []byte) string {
+	text := string(d[5:18])
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseRangeLimits decodes a display range limits descriptor
+func parseRangeLimits(d []byte) *RangeLimits {
+	// EDID 1.4 uses the flags in byte 4 to add 255 to rates above 255
+	offset := func(bit uint) uint16 {
+		if d[4]&(1<<bit) != 0 {
+			return 255
+		}
+		return 0
+	}
+	return &RangeLimits{
+		MinVRate:      uint16(d[5]) + offset(0),
+		MaxVRate:      uint16(d[6]) + offset(1),
+		MinHRate:      uint16(d[7]) + offset(2),
+		MaxHRate:      uint16(d[8]) + offset(3),
+		MaxPixelClock: uint16(d[9]) * 10,
+	}
+}
+
+// PreferredTiming returns the preferred detailed timing, which is always the first one
+func (e *EDID) PreferredTiming() *DetailedTiming {
+	if len(e.DetailedTimings) == 0 {
+		return nil
+	}
+	return &e.DetailedTimings[0]
+}
+
+// String formats the decoded EDID as human readable text
+func (e *EDID) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "EDID version:      %d.%d\n", e.Version, e.Revision)
+	fmt.Fprintf(&sb, "Manufacturer:      %s\n", e.Manufacturer)
+	fmt.Fprintf(&sb, "Product code:      0x%04X\n", e.ProductCode)
+	fmt.Fprintf(&sb, "Serial number:     %d\n", e.SerialNumber)
+	switch e.Week {
+	case 0xFF:
+		fmt.Fprintf(&sb, "Model year:        %d\n", e.Year)
+	case 0:
+		fmt.Fprintf(&sb, "Manufactured:      %d\n", e.Year)
+	default:
+		fmt.Fprintf(&sb, "Manufactured:      week %d of %d\n", e.Week, e.Year)
+	}
+	if e.MonitorName != "" {
+		fmt.Fprintf(&sb, "Monitor name:      %s\n", e.MonitorName)
+	}
+	if e.MonitorSerial != "" {
+		fmt.Fprintf(&sb, "Monitor serial:    %s\n", e.MonitorSerial)
+	}
+	if e.Digital {
+		input := "digital"
+		if e.Interface != "" {
+			input += ", " + e.Interface
+		}
+		if e.BitDepth != 0 {
+			input += fmt.Sprintf(", %d bits per color", e.BitDepth)
+		}
+		fmt.Fprintf(&sb, "Input:             %s\n", input)
+	} else {
+		fmt.Fprintf(&sb, "Input:             analog\n")
+	}
+	if e.WidthCm != 0 && e.HeightCm != 0 {
+		fmt.Fprintf(&sb, "Physical size:     %d x %d cm\n", e.WidthCm, e.HeightCm)
+	}
+	if t := e.PreferredTiming(); t != nil {
+		fmt.Fprintf(&sb, "Preferred timing:  %s\n", t)
+	}
+	if len(e.DetailedTimings) > 1 {
+		fmt.Fprintf(&sb, "Detailed timings:\n")
+		for _, t := range e.DetailedTimings[1:] {
+			fmt.Fprintf(&sb, "  %s\n", t)
+		}
+	}
+	if len(e.StandardTimings) > 0 {
+		fmt.Fprintf(&sb, "Standard timings:\n")
+		for _, t := range e.StandardTimings {
+			fmt.Fprintf(&sb, "  %dx%d @ %d Hz\n", t.Width, t.Height, t.Refresh)
+		}
+	}
+	if r := e.RangeLimits; r != nil {
+		fmt.Fprintf(&sb, "Range limits:      %d-%d Hz vertical, %d-%d kHz horizontal", r.MinVRate, r.MaxVRate, r.MinHRate, r.MaxHRate)
+		if r.MaxPixelClock != 0 {
+			fmt.Fprintf(&sb, ", max %d MHz pixel clock", r.MaxPixelClock)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "Extension blocks:  %d\n", e.Extensions)
+	for _, bad := range e.BadExtensions {
+		fmt.Fprintf(&sb, "  %v\n", bad)
+	}
+	return sb.String()
+}
+
+// String formats the timing, e.g. "2560x1440 @ 143.912 Hz (586.59 MHz, 597x336 mm)"
+func (t DetailedTiming) String() string {
+	scan := ""
+	if t.Interlaced {
+		scan = "i"
+	}
+	return fmt.Sprintf("%dx%d%s @ %.3f Hz (%.2f MHz, %dx%d mm)",
+		t.HActive, t.VActive, scan, t.Refresh(), float64(t.PixelClock)/1000, t.WidthMm, t.HeightMm)
+}
+
+// HexDump formats raw EDID bytes as 16 bytes of hex per line
+func HexDump(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 16 {
+		end := i + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		fmt.Fprintf(&sb, "%x\n", data[i:end])
+	}
+	return sb.String()
+}
+
+// VideoFormats returns the CTA-861 video formats advertised by every extension block
+func (e *EDID) VideoFormats() []VideoFormat {
+	var formats []VideoFormat
+	for _, ext := range e.CTA {
+		formats = append(formats, ext.VideoFormats...)
+	}
+	for _, ext := range e.DisplayID {
+		formats = append(formats, ext.VideoFormats...)
+	}
+	return formats
+}
+
+// AllDetailedTimings returns the detailed timings of the base block and of every extension block
+func (e *EDID) AllDetailedTimings() []DetailedTiming {
+	timings := append([]DetailedTiming{}, e.DetailedTimings...)
+	for _, ext := range e.CTA {
+		timings = append(timings, ext.DetailedTimings...)
+	}
+	for _, ext := range e.DisplayID {
+		timings = append(timings, ext.DetailedTimings...)
+	}
+	return timings
+}
+
+// HDR returns the HDR static metadata of the first extension advertising it, or nil
+func (e *EDID) HDR() *HDRStaticMetadata {
+	for _, blocks := range e.dataBlocks() {
+		if blocks.HDR != nil {
+			return blocks.HDR
+		}
+	}
+	return nil
+}
+
+// HDMI returns the HDMI link capabilities merged from every extension, or nil
+func (e *EDID) HDMI() *HDMIInfo {
+	var merged *HDMIInfo
+	for _, blocks := range e.dataBlocks() {
+		if blocks.HDMI == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &HDMIInfo{}
+		}
+		if blocks.HDMI.MaxTMDSClock > merged.MaxTMDSClock {
+			merged.MaxTMDSClock = blocks.HDMI.MaxTMDSClock
+		}
+		if blocks.HDMI.MaxTMDSCharRate > merged.MaxTMDSCharRate {
+			merged.MaxTMDSCharRate = blocks.HDMI.MaxTMDSCharRate
+		}
+		if blocks.HDMI.MaxFRLRate > merged.MaxFRLRate {
+			merged.MaxFRLRate = blocks.HDMI.MaxFRLRate
+		}
+	}
+	return merged
+}
+
+// Colorimetry returns the colorimetry formats advertised by every extension
+func (e *EDID) Colorimetry() []string {
+	var names []string
+	for _, blocks := range e.dataBlocks() {
+		names = append(names, blocks.Colorimetry...)
+	}
+	return names
+}
+
+// dataBlocks returns the CTA data blocks of every extension
+func (e *EDID) dataBlocks() []*DataBlocks {
+	var blocks []*DataBlocks
+	for _, ext := range e.CTA {
+		blocks = append(blocks, &ext.DataBlocks)
+	}
+	for _, ext := range e.DisplayID {
+		blocks = append(blocks, &ext.DataBlocks)
+	}
+	return blocks
+}
+
+// Capabilities formats the capabilities decoded from the extension blocks:
+// HDR support, colorimetry, HDMI link rates and every advertised video format
+func (e *EDID) Capabilities() string {
+	var sb strings.Builder
+	name := e.MonitorName
+	if name == "" {
+		name = fmt.Sprintf("%s 0x%04X", e.Manufacturer, e.ProductCode)
+	}
+	fmt.Fprintf(&sb, "Monitor:           %s\n", name)
+	fmt.Fprintf(&sb, "Extensions:        %d CTA-861, %d DisplayID\n", len(e.CTA), len(e.DisplayID))
+
+	if hdr := e.HDR(); hdr.HDR() {
+		var eotfs []string
+		if hdr.PQ {
+			eotfs = append(eotfs, "PQ (ST 2084)")
+		}
+		if hdr.HLG {
+			eotfs = append(eotfs, "HLG")
+		}
+		if hdr.TraditionalHDR {
+			eotfs = append(eotfs, "traditional HDR")
+		}
+		fmt.Fprintf(&sb, "HDR:               yes, %s\n", strings.Join(eotfs, ", "))
+		if hdr.MaxLuminance != 0 {
+			fmt.Fprintf(&sb, "Luminance:         max %.0f, max frame-average %.0f, min %.4f cd/m²\n",
+				hdr.MaxLuminance, hdr.MaxFrameAvgLuminance, hdr.MinLuminance)
+		}
+	} else {
+		fmt.Fprintf(&sb, "HDR:               no\n")
+	}
+	if colorimetry := e.Colorimetry(); len(colorimetry) > 0 {
+		fmt.Fprintf(&sb, "Colorimetry:       %s\n", strings.Join(colorimetry, ", "))
+	}
+
+	if hdmi := e.HDMI(); hdmi != nil {
+		if hdmi.MaxTMDSClock != 0 {
+			fmt.Fprintf(&sb, "Max TMDS clock:    %d MHz\n", hdmi.MaxTMDSClock)
+		}
+		if hdmi.MaxTMDSCharRate != 0 {
+			fmt.Fprintf(&sb, "Max TMDS rate:     %d Mcsc\n", hdmi.MaxTMDSCharRate)
+		}
+		if lanes, gbps := hdmi.FRLBandwidth(); lanes != 0 {
+			fmt.Fprintf(&sb, "Max FRL rate:      %d Gbps (%d lanes x %d Gbps)\n", uint16(lanes)*uint16(gbps), lanes, gbps)
+		} else {
+			fmt.Fprintf(&sb, "Max FRL rate:      not supported\n")
+		}
+	}
+
+	fmt.Fprintf(&sb, "Video formats:\n")
+	for _, t := range e.AllDetailedTimings() {
+		fmt.Fprintf(&sb, "  DTD: %s\n", t)
+	}
+	for _, f := range e.VideoFormats() {
+		fmt.Fprintf(&sb, "  %s\n", f)
+	}
+	for _, t := range e.StandardTimings {
+		fmt.Fprintf(&sb, "  STD: %dx%d @ %d Hz\n", t.Width, t.Height, t.Refresh)
+	}
+	return sb.String()
+}
@@ -0,0 +1,296 @@
+package edid
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withChecksum sets the last byte of the block so that it sums to 0
+func withChecksum(block []byte) []byte {
+	var sum byte
+	for _, b := range block[:BlockSize-1] {
+		sum += b
+	}
+	block[BlockSize-1] = -sum
+	return block
+}
+
+// testBaseBlock returns a minimal EDID 1.4 base block announcing the extension blocks
+func testBaseBlock(extensions byte) []byte {
+	block := make([]byte, BlockSize)
+	copy(block, header)
+	block[8], block[9] = 0x05, 0xE3 // AOC
+	block[18], block[19] = 1, 4
+	block[126] = extensions
+	return withChecksum(block)
+}
+
+// testCTABlock returns a CTA-861 revision 3 block with the data block collection
+func testCTABlock(collection ...byte) []byte {
+	block := make([]byte, BlockSize)
+	block[0], block[1], block[2] = ExtensionCTA, 3, byte(4+len(collection))
+	copy(block[4:], collection)
+	return withChecksum(block)
+}
+
+func TestParseBadExtensions(t *testing.T) {
+	// A video data block with VIC 16, 1920x1080 @ 60 Hz
+	good := testCTABlock(ctaVideoBlock<<5|1, 16)
+	// A video data block claiming more bytes than the collection holds
+	overflowing := testCTABlock(ctaVideoBlock<<5|8, 16)
+	badChecksum := testCTABlock(ctaVideoBlock<<5|1, 16)
+	badChecksum[BlockSize-1]++
+
+	join := func(blocks ...[]byte) []byte {
+		var data []byte
+		for _, b := range blocks {
+			data = append(data, b...)
+		}
+		return data
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantCTA int
+		wantBad []ExtensionError
+	}{
+		{name: "valid", data: join(testBaseBlock(1), good), wantCTA: 1},
+		{
+			name:    "undecodable block is skipped",
+			data:    join(testBaseBlock(2), overflowing, good),
+			wantCTA: 1,
+			wantBad: []ExtensionError{{Block: 1, Tag: ExtensionCTA}},
+		},
+		{
+			name:    "bad checksum",
+			data:    join(testBaseBlock(2), good, badChecksum),
+			wantCTA: 1,
+			wantBad: []ExtensionError{{Block: 2, Tag: ExtensionCTA}},
+		},
+		{
+			name:    "truncated",
+			data:    join(testBaseBlock(3), good),
+			wantCTA: 1,
+			wantBad: []ExtensionError{{Block: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if e.Manufacturer != "AOC" {
+				t.Errorf("Manufacturer = %q, want AOC", e.Manufacturer)
+			}
+			if len(e.CTA) != tt.wantCTA {
+				t.Errorf("%d CTA blocks decoded, want %d", len(e.CTA), tt.wantCTA)
+			}
+			if len(e.BadExtensions) != len(tt.wantBad) {
+				t.Fatalf("BadExtensions = %v, want %d entries", e.BadExtensions, len(tt.wantBad))
+			}
+			for i, want := range tt.wantBad {
+				got := e.BadExtensions[i]
+				if got.Block != want.Block || got.Tag != want.Tag || got.Err == nil {
+					t.Errorf("BadExtensions[%d] = %+v, want block %d with tag 0x%02X", i, got, want.Block, want.Tag)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBadBaseBlock(t *testing.T) {
+	corrupt := testBaseBlock(0)
+	corrupt[20]++
+	tests := map[string][]byte{
+		"too short":      make([]byte, 64),
+		"invalid header": make([]byte, BlockSize),
+		"bad checksum":   corrupt,
+	}
+	for name, data := range tests {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+func TestParseTestdata(t *testing.T) {
+	// The captures are synthesized to the layout of the monitors they are named
+	// after, each case notes what it carries
+	tests := []struct {
+		file          string
+		manufacturer  string
+		productCode   uint16
+		year          int
+		version       string
+		name          string
+		serial        string
+		input         string // Interface and bits per color of digital inputs
+		sizeCm        [2]uint8
+		preferred     string
+		rangeLimits   *RangeLimits
+		texts         []string
+		cta           int
+		displayID     int
+		vics          []uint8
+		hdmi          *HDMIInfo
+		hdr           string // EOTFs of the HDR static metadata block
+		extraTimings  []string
+		extraStandard []StandardTiming
+	}{
+		{
+			// TV on HDMI: EDID 1.3 with a CTA-861 block carrying the HDMI 2.1 and HDR blocks
+			file:         "lg_oled_tv.bin",
+			manufacturer: "GSM",
+			productCode:  0xC0B6,
+			year:         2021,
+			version:      "1.3",
+			name:         "LG TV SSCR2",
+			sizeCm:       [2]uint8{160, 90},
+			preferred:    "1920x1080 @ 60.000 Hz (148.50 MHz, 1600x900 mm)",
+			rangeLimits:  &RangeLimits{MinVRate: 24, MaxVRate: 120, MinHRate: 30, MaxHRate: 255, MaxPixelClock: 600},
+			cta:          1,
+			vics:         []uint8{16, 97, 4, 5, 63},
+			hdmi:         &HDMIInfo{MaxTMDSClock: 300, MaxTMDSCharRate: 600, MaxFRLRate: 5},
+			hdr:          "SDR PQ HLG",
+			extraTimings: []string{"3840x2160 @ 60.000 Hz (594.00 MHz, 1600x900 mm)"},
+			extraStandard: []StandardTiming{
+				{Width: 1280, Height: 720, Refresh: 60},
+				{Width: 1920, Height: 1080, Refresh: 60},
+			},
+		},
+		{
+			// Gaming monitor on DisplayPort: EDID 1.4 at 144 Hz with a DisplayID block holding a 120 Hz timing
+			file:         "aoc_27g2g5.bin",
+			manufacturer: "AOC",
+			productCode:  0x2702,
+			year:         2020,
+			version:      "1.4",
+			name:         "27G2G5",
+			serial:       "1A2B3C4D5E6F",
+			input:        "DisplayPort 8",
+			sizeCm:       [2]uint8{60, 34},
+			preferred:    "1920x1080 @ 144.004 Hz (330.38 MHz, 598x336 mm)",
+			rangeLimits:  &RangeLimits{MinVRate: 48, MaxVRate: 144, MinHRate: 30, MaxHRate: 160, MaxPixelClock: 340},
+			displayID:    1,
+			extraTimings: []string{"1920x1080 @ 119.996 Hz (275.30 MHz, 0x0 mm)"},
+			extraStandard: []StandardTiming{
+				{Width: 1920, Height: 1080, Refresh: 60},
+				{Width: 1920, Height: 1080, Refresh: 120},
+			},
+		},
+		{
+			// Laptop panel on eDP: EDID 1.4 without extensions, the model is in text descriptors instead of a name
+			file:         "boe_laptop_panel.bin",
+			manufacturer: "BOE",
+			productCode:  0x0A1C,
+			year:         2019,
+			version:      "1.4",
+			input:        "DisplayPort 6",
+			sizeCm:       [2]uint8{34, 19},
+			preferred:    "1920x1080 @ 59.999 Hz (138.65 MHz, 344x194 mm)",
+			texts:        []string{"BOE CQ", "NV156FHM-N4G"},
+			extraTimings: []string{"1920x1080 @ 47.999 Hz (110.92 MHz, 344x194 mm)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if len(e.BadExtensions) != 0 {
+				t.Errorf("BadExtensions = %v", e.BadExtensions)
+			}
+			if e.Manufacturer != tt.manufacturer || e.ProductCode != tt.productCode {
+				t.Errorf("id = %s %04X, want %s %04X", e.Manufacturer, e.ProductCode, tt.manufacturer, tt.productCode)
+			}
+			if e.Year != tt.year {
+				t.Errorf("Year = %d, want %d", e.Year, tt.year)
+			}
+			if version := fmt.Sprintf("%d.%d", e.Version, e.Revision); version != tt.version {
+				t.Errorf("version = %s, want %s", version, tt.version)
+			}
+			if e.MonitorName != tt.name || e.MonitorSerial != tt.serial {
+				t.Errorf("name and serial = %q %q, want %q %q", e.MonitorName, e.MonitorSerial, tt.name, tt.serial)
+			}
+			input := ""
+			if e.BitDepth != 0 {
+				input = fmt.Sprintf("%s %d", e.Interface, e.BitDepth)
+			}
+			if !e.Digital || input != tt.input {
+				t.Errorf("input = digital %v %q, want digital %q", e.Digital, input, tt.input)
+			}
+			if size := [2]uint8{e.WidthCm, e.HeightCm}; size != tt.sizeCm {
+				t.Errorf("size = %v, want %v", size, tt.sizeCm)
+			}
+			if p := e.PreferredTiming(); p == nil || p.String() != tt.preferred {
+				t.Errorf("preferred timing = %v, want %s", p, tt.preferred)
+			}
+			if !reflect.DeepEqual(e.RangeLimits, tt.rangeLimits) {
+				t.Errorf("RangeLimits = %+v, want %+v", e.RangeLimits, tt.rangeLimits)
+			}
+			if !reflect.DeepEqual(e.Texts, tt.texts) {
+				t.Errorf("Texts = %q, want %q", e.Texts, tt.texts)
+			}
+			if len(e.CTA) != tt.cta || len(e.DisplayID) != tt.displayID {
+				t.Errorf("%d CTA and %d DisplayID blocks, want %d and %d", len(e.CTA), len(e.DisplayID), tt.cta, tt.displayID)
+			}
+
+			var vics []uint8
+			for _, f := range e.VideoFormats() {
+				vics = append(vics, f.VIC)
+			}
+			if !reflect.DeepEqual(vics, tt.vics) {
+				t.Errorf("VICs = %v, want %v", vics, tt.vics)
+			}
+			if !reflect.DeepEqual(e.HDMI(), tt.hdmi) {
+				t.Errorf("HDMI = %+v, want %+v", e.HDMI(), tt.hdmi)
+			}
+			var eotfs []string
+			if hdr := e.HDR(); hdr != nil {
+				for _, eotf := range []struct {
+					name string
+					on   bool
+				}{{"SDR", hdr.SDR}, {"HDR", hdr.TraditionalHDR}, {"PQ", hdr.PQ}, {"HLG", hdr.HLG}} {
+					if eotf.on {
+						eotfs = append(eotfs, eotf.name)
+					}
+				}
+			}
+			if got := strings.Join(eotfs, " "); got != tt.hdr {
+				t.Errorf("HDR = %q, want %q", got, tt.hdr)
+			}
+
+			// Timings beyond the preferred one, from the base block and the extensions
+			var timings []string
+			for _, d := range e.DetailedTimings[1:] {
+				timings = append(timings, d.String())
+			}
+			for _, c := range e.CTA {
+				for _, d := range c.DetailedTimings {
+					timings = append(timings, d.String())
+				}
+			}
+			for _, d := range e.DisplayID {
+				for _, t := range d.DetailedTimings {
+					timings = append(timings, t.String())
+				}
+			}
+			if !reflect.DeepEqual(timings, tt.extraTimings) {
+				t.Errorf("timings = %q, want %q", timings, tt.extraTimings)
+			}
+			if !reflect.DeepEqual(e.StandardTimings, tt.extraStandard) {
+				t.Errorf("StandardTimings = %+v, want %+v", e.StandardTimings, tt.extraStandard)
+			}
+		})
+	}
+}
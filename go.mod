@@ -2,6 +2,8 @@ module windows-resolution-manager
 
 go 1.23.2
 
-require github.com/go-ole/go-ole v1.3.0
-
-require golang.org/x/sys v0.1.0 // indirect
+require (
+	github.com/go-ole/go-ole v1.3.0
+	github.com/godbus/dbus/v5 v5.1.0
+	golang.org/x/sys v0.1.0
+)